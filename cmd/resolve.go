@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gkwa/lessmay/core"
+)
+
+var resolveCmd = &cobra.Command{
+	Use:   "resolve [directories...]",
+	Short: "Interactively resolve sync conflicts in Obsidian vault",
+	Long: `This command walks through each sync conflict, shows the diff against the original and lets you
+keep the original, keep the conflict copy, keep both, merge hunk by hunk or edit the files.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := LoggerFrom(cmd.Context())
+		logger.Info("Running resolve command")
+
+		opts := commonOptions()
+
+		if err := core.ResolveInteractively(logger, args, defaultObsidianPath, skipPaths, opts, os.Stdin, os.Stdout); err != nil {
+			logger.Error(err, "Failed to resolve sync conflicts")
+			cmd.PrintErrln("Error:", err)
+			cmd.PrintErrln("Run with --verbose for more details.")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(resolveCmd)
+
+	resolveCmd.Flags().
+		StringVarP(&defaultObsidianPath, "default-path", "d", "", "Vault to scan when no directories are given (default is every vault registered with Obsidian)")
+	resolveCmd.Flags().
+		BoolVarP(&ignoreAllSpace, "ignore-all-space", "w", true, "Ignore all white space when diffing")
+	resolveCmd.Flags().
+		BoolVarP(&dryRun, "dry-run", "n", false, "Report what would be deleted, renamed or merged without changing any files")
+}
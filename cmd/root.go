@@ -4,20 +4,43 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/go-logr/logr"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"github.com/gkwa/lessmay/core"
 	"github.com/gkwa/lessmay/internal/logger"
 )
 
 var (
-	cfgFile   string
-	verbose   bool
-	logFormat string
-	skipPaths []string
-	cliLogger logr.Logger
+	cfgFile       string
+	verbose       bool
+	logFormat     string
+	skipPaths     []string
+	disposal      string
+	quarantineDir string
+	journalDir    string
+	detectors     []string
+	hostnames     []string
+	noStignore    bool
+	skipRegexes   []string
+	includes      []string
+	stConfig      string
+	stAPI         bool
+	stAddress     string
+	stAPIKey      string
+	vaults        []string
+	obsidianCfg   string
+	uriForm       string
+	opener        string
+	frontmatter   bool
+	fmIgnore      []string
+	semanticJSON  bool
+	appendOnly    []string
+	appendOnlyUnv bool
+	cliLogger     logr.Logger
 )
 
 var rootCmd = &cobra.Command{
@@ -51,7 +74,49 @@ func init() {
 	rootCmd.PersistentFlags().
 		StringVar(&logFormat, "log-format", "", "json or text (default is text)")
 	rootCmd.PersistentFlags().
-		StringSliceVar(&skipPaths, "skip-path", []string{".trash"}, "paths to skip (can be specified multiple times)")
+		StringSliceVar(&skipPaths, "skip-path", []string{".trash"}, "gitignore-style globs to skip, relative to the vault root (can be specified multiple times)")
+	rootCmd.PersistentFlags().
+		StringArrayVar(&skipRegexes, "skip-regex", nil, "regular expressions matched against paths relative to the vault root to skip (can be specified multiple times)")
+	rootCmd.PersistentFlags().
+		StringArrayVar(&includes, "include", nil, "only look at files matching these gitignore-style globs (can be specified multiple times)")
+	rootCmd.PersistentFlags().
+		StringVar(&disposal, "dispose", core.DisposeDelete, "how to get rid of files: "+strings.Join(core.DisposalStrategies, ", "))
+	rootCmd.PersistentFlags().
+		StringSliceVar(&detectors, "detector", []string{core.DetectorSyncthing}, "conflict naming schemes to detect: "+strings.Join(core.DetectorNames, ", "))
+	rootCmd.PersistentFlags().
+		StringSliceVar(&hostnames, "onedrive-hostname", nil, "computer names OneDrive appends to conflict copies (default is this machine's hostname)")
+	rootCmd.PersistentFlags().
+		BoolVar(&noStignore, "no-stignore", false, "also look at paths excluded by Syncthing's .stignore")
+	rootCmd.PersistentFlags().
+		StringVar(&stConfig, "syncthing-config", "", "Syncthing config.xml to read device names from (default is Syncthing's own location)")
+	rootCmd.PersistentFlags().
+		BoolVar(&stAPI, "syncthing", false, "use the Syncthing REST API to find folders to scan and rescan them after changes")
+	rootCmd.PersistentFlags().
+		StringVar(&stAddress, "syncthing-address", "", "Syncthing GUI/API address (default is taken from Syncthing's config)")
+	rootCmd.PersistentFlags().
+		StringVar(&stAPIKey, "syncthing-api-key", "", "Syncthing API key (default is taken from Syncthing's config)")
+	rootCmd.PersistentFlags().
+		StringArrayVar(&vaults, "vault", nil, "Obsidian vault to scan by name (can be specified multiple times)")
+	rootCmd.PersistentFlags().
+		StringVar(&obsidianCfg, "obsidian-config", "", "Obsidian's obsidian.json listing the registered vaults (default is Obsidian's own location)")
+	rootCmd.PersistentFlags().
+		StringVar(&uriForm, "uri-form", core.URIFormVault, "how obsidian:// links name files: "+strings.Join(core.URIForms, ", "))
+	rootCmd.PersistentFlags().
+		StringVar(&opener, "opener", "", "command that opens obsidian:// links (default is open on macOS, rundll32 url.dll,FileProtocolHandler on Windows, xdg-open elsewhere)")
+	rootCmd.PersistentFlags().
+		BoolVar(&frontmatter, "frontmatter", false, "compare Markdown frontmatter key by key and the note body separately")
+	rootCmd.PersistentFlags().
+		StringSliceVar(&fmIgnore, "frontmatter-ignore", core.DefaultFrontmatterIgnore, "frontmatter keys to leave out when comparing notes (can be specified multiple times)")
+	rootCmd.PersistentFlags().
+		BoolVar(&semanticJSON, "semantic-json", false, "compare .json and .canvas files by their data, ignoring key order and formatting, and merge them field by field with --merge")
+	rootCmd.PersistentFlags().
+		StringArrayVar(&appendOnly, "append-only", nil, "gitignore-style globs of notes to merge when both sides only appended lines (can be specified multiple times)")
+	rootCmd.PersistentFlags().
+		BoolVar(&appendOnlyUnv, "append-only-unversioned", false, "also merge append-only notes without an older copy in .stversions when both sides added lines after a blank line or at a list item")
+	rootCmd.PersistentFlags().
+		StringVar(&journalDir, "journal-dir", "", "directory holding the undo journal and backups (default is $XDG_STATE_HOME/lessmay)")
+	rootCmd.PersistentFlags().
+		StringVar(&quarantineDir, "quarantine-dir", "", "directory for the quarantine disposal strategy (default is $XDG_STATE_HOME/lessmay/quarantine)")
 
 	if err := viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose")); err != nil {
 		fmt.Printf("Error binding verbose flag: %v\n", err)
@@ -65,6 +130,90 @@ func init() {
 		fmt.Printf("Error binding skip-path flag: %v\n", err)
 		os.Exit(1)
 	}
+	if err := viper.BindPFlag("skip-regex", rootCmd.PersistentFlags().Lookup("skip-regex")); err != nil {
+		fmt.Printf("Error binding skip-regex flag: %v\n", err)
+		os.Exit(1)
+	}
+	if err := viper.BindPFlag("include", rootCmd.PersistentFlags().Lookup("include")); err != nil {
+		fmt.Printf("Error binding include flag: %v\n", err)
+		os.Exit(1)
+	}
+	if err := viper.BindPFlag("dispose", rootCmd.PersistentFlags().Lookup("dispose")); err != nil {
+		fmt.Printf("Error binding dispose flag: %v\n", err)
+		os.Exit(1)
+	}
+	if err := viper.BindPFlag("detector", rootCmd.PersistentFlags().Lookup("detector")); err != nil {
+		fmt.Printf("Error binding detector flag: %v\n", err)
+		os.Exit(1)
+	}
+	if err := viper.BindPFlag("onedrive-hostname", rootCmd.PersistentFlags().Lookup("onedrive-hostname")); err != nil {
+		fmt.Printf("Error binding onedrive-hostname flag: %v\n", err)
+		os.Exit(1)
+	}
+	if err := viper.BindPFlag("no-stignore", rootCmd.PersistentFlags().Lookup("no-stignore")); err != nil {
+		fmt.Printf("Error binding no-stignore flag: %v\n", err)
+		os.Exit(1)
+	}
+	if err := viper.BindPFlag("syncthing-config", rootCmd.PersistentFlags().Lookup("syncthing-config")); err != nil {
+		fmt.Printf("Error binding syncthing-config flag: %v\n", err)
+		os.Exit(1)
+	}
+	if err := viper.BindPFlag("syncthing", rootCmd.PersistentFlags().Lookup("syncthing")); err != nil {
+		fmt.Printf("Error binding syncthing flag: %v\n", err)
+		os.Exit(1)
+	}
+	if err := viper.BindPFlag("syncthing-address", rootCmd.PersistentFlags().Lookup("syncthing-address")); err != nil {
+		fmt.Printf("Error binding syncthing-address flag: %v\n", err)
+		os.Exit(1)
+	}
+	if err := viper.BindPFlag("syncthing-api-key", rootCmd.PersistentFlags().Lookup("syncthing-api-key")); err != nil {
+		fmt.Printf("Error binding syncthing-api-key flag: %v\n", err)
+		os.Exit(1)
+	}
+	if err := viper.BindPFlag("vault", rootCmd.PersistentFlags().Lookup("vault")); err != nil {
+		fmt.Printf("Error binding vault flag: %v\n", err)
+		os.Exit(1)
+	}
+	if err := viper.BindPFlag("obsidian-config", rootCmd.PersistentFlags().Lookup("obsidian-config")); err != nil {
+		fmt.Printf("Error binding obsidian-config flag: %v\n", err)
+		os.Exit(1)
+	}
+	if err := viper.BindPFlag("uri-form", rootCmd.PersistentFlags().Lookup("uri-form")); err != nil {
+		fmt.Printf("Error binding uri-form flag: %v\n", err)
+		os.Exit(1)
+	}
+	if err := viper.BindPFlag("opener", rootCmd.PersistentFlags().Lookup("opener")); err != nil {
+		fmt.Printf("Error binding opener flag: %v\n", err)
+		os.Exit(1)
+	}
+	if err := viper.BindPFlag("frontmatter", rootCmd.PersistentFlags().Lookup("frontmatter")); err != nil {
+		fmt.Printf("Error binding frontmatter flag: %v\n", err)
+		os.Exit(1)
+	}
+	if err := viper.BindPFlag("frontmatter-ignore", rootCmd.PersistentFlags().Lookup("frontmatter-ignore")); err != nil {
+		fmt.Printf("Error binding frontmatter-ignore flag: %v\n", err)
+		os.Exit(1)
+	}
+	if err := viper.BindPFlag("semantic-json", rootCmd.PersistentFlags().Lookup("semantic-json")); err != nil {
+		fmt.Printf("Error binding semantic-json flag: %v\n", err)
+		os.Exit(1)
+	}
+	if err := viper.BindPFlag("append-only", rootCmd.PersistentFlags().Lookup("append-only")); err != nil {
+		fmt.Printf("Error binding append-only flag: %v\n", err)
+		os.Exit(1)
+	}
+	if err := viper.BindPFlag("append-only-unversioned", rootCmd.PersistentFlags().Lookup("append-only-unversioned")); err != nil {
+		fmt.Printf("Error binding append-only-unversioned flag: %v\n", err)
+		os.Exit(1)
+	}
+	if err := viper.BindPFlag("journal-dir", rootCmd.PersistentFlags().Lookup("journal-dir")); err != nil {
+		fmt.Printf("Error binding journal-dir flag: %v\n", err)
+		os.Exit(1)
+	}
+	if err := viper.BindPFlag("quarantine-dir", rootCmd.PersistentFlags().Lookup("quarantine-dir")); err != nil {
+		fmt.Printf("Error binding quarantine-dir flag: %v\n", err)
+		os.Exit(1)
+	}
 }
 
 func initConfig() {
@@ -88,6 +237,63 @@ func initConfig() {
 	logFormat = viper.GetString("log-format")
 	verbose = viper.GetBool("verbose")
 	skipPaths = viper.GetStringSlice("skip-path")
+	skipRegexes = viper.GetStringSlice("skip-regex")
+	includes = viper.GetStringSlice("include")
+	disposal = viper.GetString("dispose")
+	quarantineDir = viper.GetString("quarantine-dir")
+	journalDir = viper.GetString("journal-dir")
+	detectors = viper.GetStringSlice("detector")
+	hostnames = viper.GetStringSlice("onedrive-hostname")
+	noStignore = viper.GetBool("no-stignore")
+	stConfig = viper.GetString("syncthing-config")
+	stAPI = viper.GetBool("syncthing")
+	stAddress = viper.GetString("syncthing-address")
+	stAPIKey = viper.GetString("syncthing-api-key")
+	vaults = viper.GetStringSlice("vault")
+	obsidianCfg = viper.GetString("obsidian-config")
+	uriForm = viper.GetString("uri-form")
+	opener = viper.GetString("opener")
+	frontmatter = viper.GetBool("frontmatter")
+	fmIgnore = viper.GetStringSlice("frontmatter-ignore")
+	semanticJSON = viper.GetBool("semantic-json")
+	appendOnly = viper.GetStringSlice("append-only")
+	appendOnlyUnv = viper.GetBool("append-only-unversioned")
+}
+
+// commonOptions collects the settings shared by every command that touches
+// a vault.
+func commonOptions() core.Options {
+	return core.Options{
+		IgnoreAllSpace: ignoreAllSpace,
+		DryRun:         dryRun,
+		Disposal:       disposal,
+		QuarantineDir:  quarantineDir,
+		JournalDir:     journalDir,
+		Detectors:      detectors,
+		VaultDetectors: viper.GetStringMapStringSlice("vault-detectors"),
+		Hostnames:      hostnames,
+		NoStignore:     noStignore,
+		SkipRegexes:    skipRegexes,
+		Include:        includes,
+
+		SyncthingConfig: stConfig,
+		Devices:         viper.GetStringMapString("devices"),
+
+		Syncthing:        stAPI,
+		SyncthingAddress: stAddress,
+		SyncthingAPIKey:  stAPIKey,
+
+		ObsidianConfig: obsidianCfg,
+		Vaults:         vaults,
+		URIForm:        uriForm,
+		Opener:         opener,
+
+		Frontmatter:           frontmatter,
+		FrontmatterIgnore:     fmIgnore,
+		SemanticJSON:          semanticJSON,
+		AppendOnly:            appendOnly,
+		AppendOnlyUnversioned: appendOnlyUnv,
+	}
 }
 
 func LoggerFrom(ctx context.Context, keysAndValues ...interface{}) logr.Logger {
@@ -1,12 +1,23 @@
 package cmd
 
 import (
+	"strings"
+
 	"github.com/spf13/cobra"
 
 	"github.com/gkwa/lessmay/core"
 )
 
-var defaultObsidianPath string
+var (
+	defaultObsidianPath string
+	ignoreAllSpace      bool
+	merge               bool
+	dryRun              bool
+	output              string
+	orphanAction        string
+	subsetPolicy        string
+	equivalence         string
+)
 
 var showConflictsCmd = &cobra.Command{
 	Use:     "show-conflicts [directories...]",
@@ -17,7 +28,14 @@ var showConflictsCmd = &cobra.Command{
 		logger := LoggerFrom(cmd.Context())
 		logger.Info("Running showConflicts command")
 
-		if err := core.ShowConflicts(logger, args, defaultObsidianPath, skipPaths); err != nil {
+		opts := commonOptions()
+		opts.Merge = merge
+		opts.Output = output
+		opts.OrphanAction = orphanAction
+		opts.SubsetPolicy = subsetPolicy
+		opts.Equivalence = equivalence
+
+		if err := core.ShowConflicts(logger, args, defaultObsidianPath, skipPaths, opts); err != nil {
 			logger.Error(err, "Failed to resolve sync conflicts")
 			cmd.PrintErrln("Error:", err)
 			cmd.PrintErrln("Run with --verbose for more details.")
@@ -28,8 +46,20 @@ var showConflictsCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(showConflictsCmd)
 
-	defaultObsidianPath = core.GetDefaultObsidianPath()
-
 	showConflictsCmd.Flags().
-		StringVarP(&defaultObsidianPath, "default-path", "d", defaultObsidianPath, "Default Obsidian vault path")
+		StringVarP(&defaultObsidianPath, "default-path", "d", "", "Vault to scan when no directories are given (default is every vault registered with Obsidian)")
+	showConflictsCmd.Flags().
+		BoolVarP(&ignoreAllSpace, "ignore-all-space", "w", true, "Ignore all white space when diffing")
+	showConflictsCmd.Flags().
+		StringVar(&equivalence, "equivalence", core.EquivalenceExact, "When a conflict copy counts as identical: "+strings.Join(core.EquivalenceLevels, ", "))
+	showConflictsCmd.Flags().
+		BoolVar(&merge, "merge", false, "Three-way merge conflicts using Syncthing's .stversions as the common ancestor")
+	showConflictsCmd.Flags().
+		BoolVarP(&dryRun, "dry-run", "n", false, "Report what would be deleted, renamed or merged without changing any files")
+	showConflictsCmd.Flags().
+		StringVarP(&output, "output", "o", core.OutputText, "Output format: "+strings.Join(core.OutputFormats, ", "))
+	showConflictsCmd.Flags().
+		StringVar(&subsetPolicy, "subset", core.SubsetReport, "What to do when one file only adds lines to the other: "+strings.Join(core.SubsetPolicies, ", "))
+	showConflictsCmd.Flags().
+		StringVar(&orphanAction, "orphans", core.OrphanReport, "What to do with conflicts whose original is missing: "+strings.Join(core.OrphanActions, ", "))
 }
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gkwa/lessmay/core"
+)
+
+var (
+	undoRunID string
+	undoList  bool
+)
+
+var undoCmd = &cobra.Command{
+	Use:   "undo",
+	Short: "Undo the changes made by a previous run",
+	Long: `This command replays the journal of deletions, renames and merges backwards and restores the files.
+Without --run it undoes the most recent run that has not been undone yet.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := LoggerFrom(cmd.Context())
+		logger.Info("Running undo command")
+
+		if undoList {
+			runs, err := core.JournalRuns(journalDir)
+			if err != nil {
+				logger.Error(err, "Failed to read journal")
+				cmd.PrintErrln("Error:", err)
+				return
+			}
+			for _, run := range runs {
+				status := ""
+				if run.Undone {
+					status = " (undone)"
+				}
+				fmt.Printf(
+					"%s  %s  %d changes%s\n",
+					run.ID,
+					run.Started.Format("2006-01-02 15:04:05"),
+					run.Changes,
+					status,
+				)
+			}
+			return
+		}
+
+		if err := core.Undo(logger, journalDir, undoRunID, dryRun, os.Stdout); err != nil {
+			logger.Error(err, "Failed to undo run")
+			cmd.PrintErrln("Error:", err)
+			cmd.PrintErrln("Run with --verbose for more details.")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(undoCmd)
+
+	undoCmd.Flags().
+		StringVar(&undoRunID, "run", "", "ID of the run to undo (default is the latest run)")
+	undoCmd.Flags().
+		BoolVar(&undoList, "list", false, "List recorded runs")
+	undoCmd.Flags().
+		BoolVarP(&dryRun, "dry-run", "n", false, "Report what would be restored without changing any files")
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gkwa/lessmay/core"
+)
+
+var vaultsCmd = &cobra.Command{
+	Use:   "vaults",
+	Short: "List the Obsidian vaults lessmay scans by default",
+	Long: `This command lists every vault registered in Obsidian's obsidian.json. These are scanned when no
+directories are given; pick some of them with --vault NAME.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := LoggerFrom(cmd.Context())
+		logger.Info("Running vaults command")
+
+		found, err := core.FindObsidianVaults(obsidianCfg)
+		if err != nil {
+			logger.Error(err, "Failed to read Obsidian vault registry")
+			cmd.PrintErrln("Error:", err)
+			return
+		}
+		if len(vaults) > 0 {
+			if found, err = core.SelectVaults(found, vaults); err != nil {
+				cmd.PrintErrln("Error:", err)
+				return
+			}
+		}
+		if len(found) == 0 {
+			fmt.Println("No Obsidian vaults found.")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, vault := range found {
+			status := ""
+			switch {
+			case vault.Missing:
+				status = "missing"
+			case vault.Open:
+				status = "open"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", vault.Name, vault.Path, status)
+		}
+		if err := w.Flush(); err != nil {
+			cmd.PrintErrln("Error:", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(vaultsCmd)
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gkwa/lessmay/core"
+)
+
+var debounce time.Duration
+
+var watchCmd = &cobra.Command{
+	Use:   "watch [directories...]",
+	Short: "Resolve sync conflicts as Syncthing creates them",
+	Long: `This command watches the given directories recursively and runs the show-conflicts pipeline on every
+sync conflict file as soon as Syncthing has finished writing it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := LoggerFrom(cmd.Context())
+		logger.Info("Running watch command")
+
+		opts := commonOptions()
+		opts.Merge = merge
+		opts.Output = output
+		opts.OrphanAction = orphanAction
+		opts.SubsetPolicy = subsetPolicy
+		opts.Equivalence = equivalence
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		if err := core.Watch(ctx, logger, args, defaultObsidianPath, skipPaths, opts, debounce); err != nil {
+			logger.Error(err, "Failed to watch for sync conflicts")
+			cmd.PrintErrln("Error:", err)
+			cmd.PrintErrln("Run with --verbose for more details.")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+
+	watchCmd.Flags().
+		StringVarP(&defaultObsidianPath, "default-path", "d", "", "Vault to scan when no directories are given (default is every vault registered with Obsidian)")
+	watchCmd.Flags().
+		DurationVar(&debounce, "debounce", core.DefaultDebounce, "How long a conflict file must be unchanged before it is resolved")
+	watchCmd.Flags().
+		BoolVarP(&ignoreAllSpace, "ignore-all-space", "w", true, "Ignore all white space when diffing")
+	watchCmd.Flags().
+		StringVar(&equivalence, "equivalence", core.EquivalenceExact, "When a conflict copy counts as identical: "+strings.Join(core.EquivalenceLevels, ", "))
+	watchCmd.Flags().
+		BoolVar(&merge, "merge", false, "Three-way merge conflicts using Syncthing's .stversions as the common ancestor")
+	watchCmd.Flags().
+		BoolVarP(&dryRun, "dry-run", "n", false, "Report what would be deleted, renamed or merged without changing any files")
+	watchCmd.Flags().
+		StringVarP(&output, "output", "o", core.OutputText, "Output format: "+core.OutputText+", "+core.OutputNDJSON)
+	watchCmd.Flags().
+		StringVar(&subsetPolicy, "subset", core.SubsetReport, "What to do when one file only adds lines to the other: "+strings.Join(core.SubsetPolicies, ", "))
+	watchCmd.Flags().
+		StringVar(&orphanAction, "orphans", core.OrphanReport, "What to do with conflicts whose original is missing: "+strings.Join(core.OrphanActions, ", "))
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func (o *FileOps) keepOriginal(pair ConflictPair) error {
+	if err := o.Remove(pair.ConflictPath); err != nil {
+		return fmt.Errorf("error deleting conflict file: %w", err)
+	}
+	return nil
+}
+
+func (o *FileOps) keepConflict(pair ConflictPair) error {
+	if err := o.Remove(pair.OriginalPath); err != nil {
+		return fmt.Errorf("error disposing of original file: %w", err)
+	}
+	if err := o.Rename(pair.ConflictPath, pair.OriginalPath); err != nil {
+		return fmt.Errorf("error replacing original file: %w", err)
+	}
+	return nil
+}
+
+func (o *FileOps) keepBoth(pair ConflictPair) (string, error) {
+	target, err := keepBothPath(pair)
+	if err != nil {
+		return "", err
+	}
+	if err := o.Rename(pair.ConflictPath, target); err != nil {
+		return "", fmt.Errorf("error renaming conflict file: %w", err)
+	}
+	return target, nil
+}
+
+func keepBothPath(pair ConflictPair) (string, error) {
+	base := strings.TrimSuffix(pair.OriginalPath, pair.Ext)
+	timestamp := pair.Timestamp
+	if timestamp.IsZero() {
+		timestamp = pair.ConflictModTime
+	}
+	label := "conflict " + timestamp.Format("2006-01-02 150405")
+	if pair.DeviceID != "" {
+		label += " " + pair.DeviceID
+	}
+
+	for i := 1; i < 100; i++ {
+		candidate := fmt.Sprintf("%s (%s)%s", base, label, pair.Ext)
+		if i > 1 {
+			candidate = fmt.Sprintf("%s (%s %d)%s", base, label, i, pair.Ext)
+		}
+		_, err := os.Stat(candidate)
+		if errors.Is(err, fs.ErrNotExist) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("error checking %s: %w", candidate, err)
+		}
+	}
+
+	return "", fmt.Errorf(
+		"no free name for conflict copy in %s",
+		filepath.Dir(pair.OriginalPath),
+	)
+}
+
+// errConflictKept marks a merge that reached the original but whose
+// conflict copy could not be removed afterwards.
+var errConflictKept = errors.New("merged into the original, but the conflict copy is still there")
+
+func (o *FileOps) writeMerged(pair ConflictPair, content []byte) error {
+	if err := o.WriteFile(pair.OriginalPath, content); err != nil {
+		return fmt.Errorf("error writing merged file: %w", err)
+	}
+	if err := o.Remove(pair.ConflictPath); err != nil {
+		return fmt.Errorf("%w: error deleting conflict file: %w", errConflictKept, err)
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gkwa/lessmay/internal/diff"
+)
+
+// AppendOnlyMerger merges notes that are only ever added to, such as daily
+// notes and logs, when both devices appended lines to the same text. It
+// only looks at files matching one of its globs, which are relative to the
+// vault: the nearest directory holding .obsidian or, failing that,
+// .stfolder.
+//
+// Telling an added line from an edited one needs the older copy Syncthing
+// keeps in .stversions. With unversioned set, notes without one are still
+// merged when both sides added lines at a boundary: after a blank line, or
+// where both additions start a list item.
+type AppendOnlyMerger struct {
+	globs       []globPattern
+	unversioned bool
+}
+
+func NewAppendOnlyMerger(patterns []string, unversioned bool) (*AppendOnlyMerger, error) {
+	globs, err := compileGlobs(patterns)
+	if err != nil {
+		return nil, fmt.Errorf("append-only: %w", err)
+	}
+	return &AppendOnlyMerger{globs: globs, unversioned: unversioned}, nil
+}
+
+func (m *AppendOnlyMerger) matches(path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	root := findAncestorWith(filepath.Dir(abs), ".obsidian")
+	if root == "" {
+		root = findAncestorWith(filepath.Dir(abs), ".stfolder")
+	}
+	if root == "" {
+		root = filepath.Dir(abs)
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return false
+	}
+	return matchesAny(m.globs, filepath.ToSlash(rel), false)
+}
+
+func (m *AppendOnlyMerger) Merge(pair ConflictPair) (MergeResult, error) {
+	result := MergeResult{Strategy: "append-only"}
+	if !m.matches(pair.OriginalPath) {
+		return result, nil
+	}
+
+	originalContent, err := os.ReadFile(pair.OriginalPath)
+	if err != nil {
+		return result, fmt.Errorf("error reading original file: %w", err)
+	}
+	conflictContent, err := os.ReadFile(pair.ConflictPath)
+	if err != nil {
+		return result, fmt.Errorf("error reading conflict file: %w", err)
+	}
+	if isBinary(originalContent) || isBinary(conflictContent) {
+		result.Reason = "binary files cannot be merged"
+		return result, nil
+	}
+
+	original := appendLines(originalContent)
+	conflict := appendLines(conflictContent)
+	prefix := commonPrefix(original, conflict)
+	originalTail, conflictTail := original[prefix:], conflict[prefix:]
+
+	reason, err := m.appendedOnly(pair, original, conflict, prefix)
+	if err != nil {
+		return result, err
+	}
+	if reason != "" {
+		result.Reason = reason
+		return result, nil
+	}
+
+	// What was written first goes first; Syncthing gives the older copy
+	// the conflict name, so ties go to the conflict copy.
+	tails := [][]string{conflictTail, originalTail}
+	if pair.OriginalModTime.Before(pair.ConflictModTime) {
+		tails[0], tails[1] = originalTail, conflictTail
+	}
+
+	merged := append([]string{}, original[:prefix]...)
+	merged = append(merged, tails[0]...)
+	merged = append(merged, tails[1]...)
+	result.Clean = true
+	result.Content = []byte(strings.Join(merged, ""))
+	return result, nil
+}
+
+// appendedOnly returns why the two versions cannot be told apart from an
+// edit, or "" if both only added lines. With an older copy in .stversions,
+// both must start with all of it and share no line after it: a line both
+// have past the copy means the copy is older than where they split, and
+// that line may have been edited on one side. Without a copy, one version
+// must start with the whole of the other unless the boundary heuristic is
+// turned on.
+func (m *AppendOnlyMerger) appendedOnly(
+	pair ConflictPair,
+	original, conflict []string,
+	prefix int,
+) (string, error) {
+	basePath, err := findMergeBase(pair)
+	if err != nil {
+		return "", err
+	}
+	if basePath != "" {
+		baseContent, err := os.ReadFile(basePath)
+		if err != nil {
+			return "", fmt.Errorf("error reading %s: %w", basePath, err)
+		}
+		base := appendLines(baseContent)
+		if commonPrefix(base, original) < len(base) || commonPrefix(base, conflict) < len(base) {
+			return "not both versions start with the whole of " + basePath, nil
+		}
+		if line := sharedLine(original[len(base):], conflict[len(base):]); line != "" {
+			return fmt.Sprintf("both versions added %q after %s, which is older than where they split", line, basePath), nil
+		}
+		return editedTail(original[prefix:], conflict[prefix:]), nil
+	}
+
+	if prefix == len(original) || prefix == len(conflict) {
+		return "", nil
+	}
+	if !m.unversioned {
+		return "both sides changed the end and there is no older version in " +
+			stversionsDir + " to tell additions from edits", nil
+	}
+	if prefix == 0 || (strings.TrimSpace(original[prefix-1]) != "" &&
+		!(isListItem(original[prefix]) && isListItem(conflict[prefix]))) {
+		return "both sides changed the end, not after a blank line or at a list item, " +
+			"and there is no older version in " + stversionsDir, nil
+	}
+	if line := sharedLine(original[prefix:], conflict[prefix:]); line != "" {
+		return fmt.Sprintf("both versions added %q", line), nil
+	}
+	return editedTail(original[prefix:], conflict[prefix:]), nil
+}
+
+// sharedLine returns a line, other than a blank one, found in both a and b.
+func sharedLine(a, b []string) string {
+	seen := map[string]bool{}
+	for _, line := range a {
+		if strings.TrimSpace(line) != "" {
+			seen[line] = true
+		}
+	}
+	for _, line := range b {
+		if seen[line] {
+			return strings.TrimRight(line, "\r\n")
+		}
+	}
+	return ""
+}
+
+// editedTail reports when the first added line on one side starts with
+// the first added line on the other, which reads as that line edited
+// rather than a new one added.
+func editedTail(originalTail, conflictTail []string) string {
+	if len(originalTail) == 0 || len(conflictTail) == 0 {
+		return ""
+	}
+	a := strings.TrimRight(originalTail[0], "\r\n")
+	b := strings.TrimRight(conflictTail[0], "\r\n")
+	if strings.TrimSpace(a) == "" || strings.TrimSpace(b) == "" {
+		return ""
+	}
+	if strings.HasPrefix(a, b) || strings.HasPrefix(b, a) {
+		return fmt.Sprintf("%q and %q look like one line edited on one side", a, b)
+	}
+	return ""
+}
+
+// isListItem reports whether line starts a Markdown list item or task.
+func isListItem(line string) bool {
+	trimmed := strings.TrimLeft(line, " \t")
+	for _, marker := range []string{"- ", "* ", "+ "} {
+		if strings.HasPrefix(trimmed, marker) {
+			return true
+		}
+	}
+	digits := len(trimmed) - len(strings.TrimLeft(trimmed, "0123456789"))
+	return digits > 0 && (strings.HasPrefix(trimmed[digits:], ". ") || strings.HasPrefix(trimmed[digits:], ") "))
+}
+
+// appendLines splits content into lines that all end in a newline, so that
+// text added after a final line without one still reads as appended.
+func appendLines(content []byte) []string {
+	lines := diff.SplitLines(string(content))
+	if n := len(lines); n > 0 && !strings.HasSuffix(lines[n-1], "\n") {
+		lines[n-1] += "\n"
+	}
+	return lines
+}
+
+func commonPrefix(a, b []string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
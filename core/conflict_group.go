@@ -0,0 +1,39 @@
+package core
+
+import "sort"
+
+// ConflictGroup is every conflict copy of one original, resolved together.
+type ConflictGroup struct {
+	OriginalPath string
+	Pairs        []ConflictPair
+}
+
+// GroupConflicts groups pairs by original, keeping the order in which the
+// originals were first seen. Within a group the oldest generation comes
+// first, then the oldest conflict.
+func GroupConflicts(pairs []ConflictPair) []ConflictGroup {
+	var groups []ConflictGroup
+	index := map[string]int{}
+
+	for _, pair := range pairs {
+		i, ok := index[pair.OriginalPath]
+		if !ok {
+			i = len(groups)
+			index[pair.OriginalPath] = i
+			groups = append(groups, ConflictGroup{OriginalPath: pair.OriginalPath})
+		}
+		groups[i].Pairs = append(groups[i].Pairs, pair)
+	}
+
+	for _, group := range groups {
+		sort.SliceStable(group.Pairs, func(i, j int) bool {
+			a, b := group.Pairs[i], group.Pairs[j]
+			if a.Generation != b.Generation {
+				return a.Generation < b.Generation
+			}
+			return a.Timestamp.Before(b.Timestamp)
+		})
+	}
+
+	return groups
+}
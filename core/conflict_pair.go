@@ -0,0 +1,106 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+type ConflictPair struct {
+	ConflictPath    string
+	OriginalPath    string
+	Timestamp       time.Time
+	DeviceID        string
+	DeviceName      string
+	Ext             string
+	Detector        string
+	Generation      int
+	Parent          string
+	ConflictSize    int64
+	OriginalSize    int64
+	ConflictModTime time.Time
+	OriginalModTime time.Time
+	// Orphan is set when the original no longer exists, usually because the
+	// note was renamed or deleted on another device.
+	Orphan bool
+	// Classification is set once the pair has been compared.
+	Classification string
+}
+
+// NewConflictPair uses the Syncthing naming scheme unless detectors are
+// given, in which case the first one that recognises the name wins.
+func NewConflictPair(
+	conflictFile string,
+	detectors ...ConflictDetector,
+) (ConflictPair, error) {
+	detection, detector, ok := detect(conflictFile, detectors)
+	if !ok {
+		return ConflictPair{}, fmt.Errorf(
+			"not a sync conflict file: %s",
+			conflictFile,
+		)
+	}
+	return pairFromDetection(conflictFile, detection, detector)
+}
+
+func pairFromDetection(
+	conflictFile string,
+	detection Detection,
+	detector string,
+) (ConflictPair, error) {
+	pair := ConflictPair{
+		ConflictPath: conflictFile,
+		OriginalPath: detection.OriginalPath,
+		Timestamp:    detection.Timestamp,
+		DeviceID:     detection.DeviceID,
+		Ext:          detection.Ext,
+		Detector:     detector,
+		Generation:   detection.Generation,
+		Parent:       detection.Parent,
+	}
+
+	if err := pair.stat(); err != nil {
+		return ConflictPair{}, err
+	}
+
+	return pair, nil
+}
+
+// DeviceLabel names the device that wrote the conflict copy, with its ID
+// when a friendly name is known.
+func (p ConflictPair) DeviceLabel() string {
+	if p.DeviceName == "" {
+		return p.DeviceID
+	}
+	if p.DeviceID == "" {
+		return p.DeviceName
+	}
+	return p.DeviceName + " (" + p.DeviceID + ")"
+}
+
+func (p *ConflictPair) stat() error {
+	info, err := os.Stat(p.ConflictPath)
+	if err != nil {
+		return fmt.Errorf("error reading conflict file: %w", err)
+	}
+	p.ConflictSize = info.Size()
+	p.ConflictModTime = info.ModTime()
+
+	info, err = os.Stat(p.OriginalPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		p.OriginalSize = 0
+		p.OriginalModTime = time.Time{}
+		p.Orphan = true
+	case err != nil:
+		return fmt.Errorf("error reading original file: %w", err)
+	default:
+		p.OriginalSize = info.Size()
+		p.OriginalModTime = info.ModTime()
+		p.Orphan = false
+	}
+
+	return nil
+}
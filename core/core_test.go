@@ -1,12 +1,29 @@
 package core
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/go-logr/logr"
 	"github.com/go-logr/logr/testr"
+	homedir "github.com/mitchellh/go-homedir"
+
+	"github.com/gkwa/lessmay/internal/diff"
 )
 
 type mockFileFinder struct {
@@ -16,28 +33,31 @@ type mockFileFinder struct {
 
 func (m *mockFileFinder) FindSyncConflictFiles(
 	paths, skipPaths []string,
-) ([]string, error) {
-	return m.files, m.err
+) ([]ConflictPair, error) {
+	var pairs []ConflictPair
+	for _, file := range m.files {
+		original, _ := originalPath(file)
+		pairs = append(pairs, ConflictPair{
+			ConflictPath: file,
+			OriginalPath: original,
+		})
+	}
+	return pairs, m.err
 }
 
 type mockDiffRunner struct {
 	calls []struct {
-		conflictFile string
-		originalFile string
-		count        int
+		pair  ConflictPair
+		count int
 	}
 	err error
 }
 
-func (m *mockDiffRunner) RunDiff(
-	conflictFile, originalFile string,
-	count int,
-) error {
+func (m *mockDiffRunner) RunDiff(pair ConflictPair, count int) error {
 	m.calls = append(m.calls, struct {
-		conflictFile string
-		originalFile string
-		count        int
-	}{conflictFile, originalFile, count})
+		pair  ConflictPair
+		count int
+	}{pair, count})
 	return m.err
 }
 
@@ -47,7 +67,7 @@ type mockFileComparer struct {
 }
 
 func (m *mockFileComparer) CompareAndDelete(
-	conflictFile, originalFile string,
+	pair ConflictPair,
 ) (bool, error) {
 	return m.deleted, m.err
 }
@@ -164,6 +184,9 @@ func TestDefaultFileFinder_FindSyncConflictFiles(t *testing.T) {
 		"subdir/file2.sync-conflict-20240818-215425-I2NUVZU.md",
 		"file3.md",
 		".trash/file4.sync-conflict-20240818-215425-I2NUVZU.md",
+		"board.sync-conflict-20240818-215425-I2NUVZU.canvas",
+		"image.sync-conflict-20240818-215425-I2NUVZU.png",
+		"Makefile.sync-conflict-20240818-215425-I2NUVZU",
 	}
 
 	for _, file := range files {
@@ -179,7 +202,7 @@ func TestDefaultFileFinder_FindSyncConflictFiles(t *testing.T) {
 	}
 
 	finder := &DefaultFileFinder{}
-	foundFiles, err := finder.FindSyncConflictFiles(
+	foundPairs, err := finder.FindSyncConflictFiles(
 		[]string{tempDir},
 		[]string{".trash"},
 	)
@@ -188,10 +211,22 @@ func TestDefaultFileFinder_FindSyncConflictFiles(t *testing.T) {
 	}
 
 	expectedFiles := []string{
+		filepath.Join(
+			tempDir,
+			"Makefile.sync-conflict-20240818-215425-I2NUVZU",
+		),
+		filepath.Join(
+			tempDir,
+			"board.sync-conflict-20240818-215425-I2NUVZU.canvas",
+		),
 		filepath.Join(
 			tempDir,
 			"file1.sync-conflict-20240818-215425-I2NUVZU.md",
 		),
+		filepath.Join(
+			tempDir,
+			"image.sync-conflict-20240818-215425-I2NUVZU.png",
+		),
 		filepath.Join(
 			tempDir,
 			"subdir",
@@ -199,21 +234,144 @@ func TestDefaultFileFinder_FindSyncConflictFiles(t *testing.T) {
 		),
 	}
 
-	if len(foundFiles) != len(expectedFiles) {
-		t.Errorf(
+	if len(foundPairs) != len(expectedFiles) {
+		t.Fatalf(
 			"Expected %d files, but got %d",
 			len(expectedFiles),
-			len(foundFiles),
+			len(foundPairs),
 		)
 	}
 
-	for i, file := range foundFiles {
-		if file != expectedFiles[i] {
-			t.Errorf("Expected file %s, but got %s", expectedFiles[i], file)
+	for i, pair := range foundPairs {
+		if pair.ConflictPath != expectedFiles[i] {
+			t.Errorf(
+				"Expected file %s, but got %s",
+				expectedFiles[i],
+				pair.ConflictPath,
+			)
 		}
 	}
 }
 
+func TestNewConflictPair(t *testing.T) {
+	tempDir := t.TempDir()
+
+	conflictFile := filepath.Join(
+		tempDir,
+		"note.sync-conflict-20240818-215425-I2NUVZU.md",
+	)
+	originalFile := filepath.Join(tempDir, "note.md")
+
+	if err := os.WriteFile(conflictFile, []byte("conflict"), 0o644); err != nil {
+		t.Fatalf("Failed to write conflict file: %v", err)
+	}
+	if err := os.WriteFile(originalFile, []byte("original!"), 0o644); err != nil {
+		t.Fatalf("Failed to write original file: %v", err)
+	}
+
+	pair, err := NewConflictPair(conflictFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expectedTime := time.Date(2024, 8, 18, 21, 54, 25, 0, time.Local)
+	if !pair.Timestamp.Equal(expectedTime) {
+		t.Errorf("Expected timestamp %v, got %v", expectedTime, pair.Timestamp)
+	}
+	if pair.OriginalPath != originalFile {
+		t.Errorf("Expected original %s, got %s", originalFile, pair.OriginalPath)
+	}
+	if pair.DeviceID != "I2NUVZU" {
+		t.Errorf("Expected device ID I2NUVZU, got %s", pair.DeviceID)
+	}
+	if pair.Ext != ".md" {
+		t.Errorf("Expected extension .md, got %s", pair.Ext)
+	}
+	if pair.ConflictSize != 8 || pair.OriginalSize != 9 {
+		t.Errorf(
+			"Expected sizes 8 and 9, got %d and %d",
+			pair.ConflictSize,
+			pair.OriginalSize,
+		)
+	}
+	if pair.ConflictModTime.IsZero() || pair.OriginalModTime.IsZero() {
+		t.Error("Expected modification times to be set")
+	}
+
+	if _, err := NewConflictPair(originalFile); err == nil {
+		t.Error("Expected an error for a non-conflict file, but got none")
+	}
+}
+
+func TestOriginalPath(t *testing.T) {
+	tests := []struct {
+		name         string
+		conflictFile string
+		expected     string
+		expectOK     bool
+	}{
+		{
+			name:         "markdown",
+			conflictFile: "/vault/note.sync-conflict-20240818-215425-I2NUVZU.md",
+			expected:     "/vault/note.md",
+			expectOK:     true,
+		},
+		{
+			name:         "canvas",
+			conflictFile: "/vault/board.sync-conflict-20240818-215425-I2NUVZU.canvas",
+			expected:     "/vault/board.canvas",
+			expectOK:     true,
+		},
+		{
+			name:         "multiple dots",
+			conflictFile: "/vault/data.tar.sync-conflict-20240818-215425-I2NUVZU.gz",
+			expected:     "/vault/data.tar.gz",
+			expectOK:     true,
+		},
+		{
+			name:         "no extension",
+			conflictFile: "/vault/Makefile.sync-conflict-20240818-215425-I2NUVZU",
+			expected:     "/vault/Makefile",
+			expectOK:     true,
+		},
+		{
+			name:         "dotfile",
+			conflictFile: "/vault/.sync-conflict-20240818-215425-I2NUVZU.gitignore",
+			expected:     "/vault/.gitignore",
+			expectOK:     true,
+		},
+		{
+			name:         "chained",
+			conflictFile: "/vault/note.sync-conflict-20240818-215425-AAAA.sync-conflict-20240819-101010-BBBB.md",
+			expected:     "/vault/note.md",
+			expectOK:     true,
+		},
+		{
+			name:         "chained without extension",
+			conflictFile: "/vault/Makefile.sync-conflict-20240818-215425-AAAA.sync-conflict-20240819-101010-BBBB",
+			expected:     "/vault/Makefile",
+			expectOK:     true,
+		},
+		{
+			name:         "not a conflict",
+			conflictFile: "/vault/note.md",
+			expectOK:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := originalPath(tt.conflictFile)
+			if ok != tt.expectOK {
+				t.Fatalf("Expected ok to be %v, got %v", tt.expectOK, ok)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %s, but got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
 func TestDefaultFileComparer_CompareAndDelete(t *testing.T) {
 	tempDir, err := os.MkdirTemp("", "file_comparer_test")
 	if err != nil {
@@ -225,6 +383,7 @@ func TestDefaultFileComparer_CompareAndDelete(t *testing.T) {
 		name            string
 		conflictContent string
 		originalContent string
+		dryRun          bool
 		expectDeleted   bool
 	}{
 		{
@@ -233,6 +392,13 @@ func TestDefaultFileComparer_CompareAndDelete(t *testing.T) {
 			originalContent: "test content",
 			expectDeleted:   true,
 		},
+		{
+			name:            "identical files dry run",
+			conflictContent: "test content",
+			originalContent: "test content",
+			dryRun:          true,
+			expectDeleted:   true,
+		},
 		{
 			name:            "different files",
 			conflictContent: "test content 1",
@@ -256,11 +422,14 @@ func TestDefaultFileComparer_CompareAndDelete(t *testing.T) {
 				t.Fatalf("Failed to write original file: %v", err)
 			}
 
-			comparer := &DefaultFileComparer{}
-			deleted, err := comparer.CompareAndDelete(
-				conflictFile,
-				originalFile,
-			)
+			var out bytes.Buffer
+			comparer := &DefaultFileComparer{
+				Ops: &FileOps{DryRun: tt.dryRun, Out: &out},
+			}
+			deleted, err := comparer.CompareAndDelete(ConflictPair{
+				ConflictPath: conflictFile,
+				OriginalPath: originalFile,
+			})
 			if err != nil {
 				t.Fatalf("CompareAndDelete failed: %v", err)
 			}
@@ -273,7 +442,7 @@ func TestDefaultFileComparer_CompareAndDelete(t *testing.T) {
 				)
 			}
 
-			if tt.expectDeleted {
+			if tt.expectDeleted && !tt.dryRun {
 				if _, err := os.Stat(conflictFile); !os.IsNotExist(err) {
 					t.Errorf(
 						"Expected conflict file to be deleted, but it still exists",
@@ -284,6 +453,2524 @@ func TestDefaultFileComparer_CompareAndDelete(t *testing.T) {
 					t.Errorf("Expected conflict file to exist, but it was deleted")
 				}
 			}
+
+			if tt.dryRun {
+				if _, err := os.Stat(conflictFile); err != nil {
+					t.Errorf("Expected dry run to leave the conflict file on disk: %v", err)
+				}
+				if !strings.Contains(out.String(), "[dry-run] would delete "+conflictFile) {
+					t.Errorf("Expected a dry-run notice, got %q", out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestDefaultDiffRunner_RunDiff(t *testing.T) {
+	tempDir := t.TempDir()
+
+	conflictFile := filepath.Join(
+		tempDir,
+		"note.sync-conflict-20240818-215425-I2NUVZU.md",
+	)
+	originalFile := filepath.Join(tempDir, "note.md")
+
+	err := os.WriteFile(conflictFile, []byte("same\nconflict  line\n"), 0o644)
+	if err != nil {
+		t.Fatalf("Failed to write conflict file: %v", err)
+	}
+	err = os.WriteFile(originalFile, []byte("same\noriginal line\n"), 0o644)
+	if err != nil {
+		t.Fatalf("Failed to write original file: %v", err)
+	}
+
+	var buf bytes.Buffer
+	differ := &DefaultDiffRunner{Out: &buf, IgnoreAllSpace: true}
+	err = differ.RunDiff(ConflictPair{
+		ConflictPath: conflictFile,
+		OriginalPath: originalFile,
+		DeviceID:     "I2NUVZU",
+		DeviceName:   "work-laptop",
+	}, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	output := buf.String()
+	for _, expected := range []string{
+		"# diff: 1",
+		"--- " + conflictFile + "\twork-laptop (I2NUVZU)\n",
+		"'obsidian://open?path=" + encodeURIComponent(filepath.ToSlash(originalFile)) + "'",
+		"@@ -1,2 +1,2 @@",
+		"-conflict  line",
+		"+original line",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, output)
+		}
+	}
+}
+
+func TestInteractiveResolver_Resolve(t *testing.T) {
+	const conflictName = "note.sync-conflict-20240818-215425-I2NUVZU.md"
+
+	tests := []struct {
+		name             string
+		input            string
+		dryRun           bool
+		expectedOriginal string
+		expectConflict   bool
+		expectedKeptBoth string
+	}{
+		{
+			name:             "keep original",
+			input:            "o\n",
+			expectedOriginal: "a\noriginal\nc\n",
+		},
+		{
+			name:             "keep conflict",
+			input:            "c\n",
+			expectedOriginal: "a\nconflict\nc\n",
+		},
+		{
+			name:             "keep both",
+			input:            "b\n",
+			expectedOriginal: "a\noriginal\nc\n",
+			expectedKeptBoth: "note (conflict 2024-08-18 215425 I2NUVZU).md",
+		},
+		{
+			name:             "merge both sides",
+			input:            "m\nb\n",
+			expectedOriginal: "a\noriginal\nconflict\nc\n",
+		},
+		{
+			name:             "abort merge then skip",
+			input:            "m\na\ns\n",
+			expectedOriginal: "a\noriginal\nc\n",
+			expectConflict:   true,
+		},
+		{
+			name:             "keep conflict dry run",
+			input:            "c\n",
+			dryRun:           true,
+			expectedOriginal: "a\noriginal\nc\n",
+			expectConflict:   true,
+		},
+		{
+			name:             "quit",
+			input:            "q\n",
+			expectedOriginal: "a\noriginal\nc\n",
+			expectConflict:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			conflictFile := filepath.Join(tempDir, conflictName)
+			originalFile := filepath.Join(tempDir, "note.md")
+
+			err := os.WriteFile(conflictFile, []byte("a\nconflict\nc\n"), 0o644)
+			if err != nil {
+				t.Fatalf("Failed to write conflict file: %v", err)
+			}
+			err = os.WriteFile(originalFile, []byte("a\noriginal\nc\n"), 0o644)
+			if err != nil {
+				t.Fatalf("Failed to write original file: %v", err)
+			}
+
+			var out bytes.Buffer
+			resolver, err := NewInteractiveResolver(
+				testr.New(t),
+				Options{DryRun: tt.dryRun, JournalDir: t.TempDir()},
+				strings.NewReader(tt.input),
+				&out,
+			)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if err := resolver.Resolve([]string{tempDir}, nil); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			content, err := os.ReadFile(originalFile)
+			if err != nil {
+				t.Fatalf("Failed to read original file: %v", err)
+			}
+			if string(content) != tt.expectedOriginal {
+				t.Errorf(
+					"Expected original %q, got %q",
+					tt.expectedOriginal,
+					string(content),
+				)
+			}
+
+			_, err = os.Stat(conflictFile)
+			if exists := err == nil; exists != tt.expectConflict {
+				t.Errorf(
+					"Expected conflict file to exist: %v, got %v",
+					tt.expectConflict,
+					exists,
+				)
+			}
+
+			if tt.expectedKeptBoth != "" {
+				kept := filepath.Join(tempDir, tt.expectedKeptBoth)
+				if _, err := os.Stat(kept); err != nil {
+					t.Errorf("Expected %s to exist: %v", kept, err)
+				}
+			}
+		})
+	}
+}
+
+func TestThreeWayMerger_Merge(t *testing.T) {
+	tests := []struct {
+		name            string
+		base            string
+		original        string
+		conflict        string
+		expectClean     bool
+		expectedContent string
+	}{
+		{
+			name:            "changes in different regions",
+			base:            "title\n\nfirst\nsecond\nthird\n",
+			original:        "title\n\nfirst edited\nsecond\nthird\n",
+			conflict:        "title\n\nfirst\nsecond\nthird edited\n",
+			expectClean:     true,
+			expectedContent: "title\n\nfirst edited\nsecond\nthird edited\n",
+		},
+		{
+			name:        "overlapping changes",
+			base:        "title\n\nfirst\n",
+			original:    "title\n\nfirst from original\n",
+			conflict:    "title\n\nfirst from conflict\n",
+			expectClean: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			versionsDir := filepath.Join(tempDir, ".stversions", "sub")
+			if err := os.MkdirAll(versionsDir, 0o755); err != nil {
+				t.Fatalf("Failed to create versions directory: %v", err)
+			}
+			if err := os.MkdirAll(filepath.Join(tempDir, "sub"), 0o755); err != nil {
+				t.Fatalf("Failed to create directory: %v", err)
+			}
+
+			files := map[string]string{
+				".stversions/sub/note~20240101-000000.md": "stale\n",
+				".stversions/sub/note~20240301-000000.md": tt.base,
+				".stversions/sub/note~29990101-000000.md": "future\n",
+				"sub/note.md": tt.original,
+				"sub/note.sync-conflict-20240818-215425-I2NUVZU.md": tt.conflict,
+			}
+			for name, content := range files {
+				path := filepath.Join(tempDir, filepath.FromSlash(name))
+				if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+					t.Fatalf("Failed to write %s: %v", name, err)
+				}
+			}
+
+			pair, err := NewConflictPair(filepath.Join(
+				tempDir,
+				"sub",
+				"note.sync-conflict-20240818-215425-I2NUVZU.md",
+			))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			result, err := (&ThreeWayMerger{}).Merge(pair)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Clean != tt.expectClean {
+				t.Fatalf(
+					"Expected clean to be %v, got %v (%s)",
+					tt.expectClean,
+					result.Clean,
+					result.Reason,
+				)
+			}
+			if !tt.expectClean && result.Reason == "" {
+				t.Error("Expected a reason for the failed merge")
+			}
+			if string(result.Content) != tt.expectedContent {
+				t.Errorf(
+					"Expected content %q, got %q",
+					tt.expectedContent,
+					string(result.Content),
+				)
+			}
 		})
 	}
 }
+
+func TestSyncConflictResolver_MergeReason(t *testing.T) {
+	vault := t.TempDir()
+	if err := os.Mkdir(filepath.Join(vault, stversionsDir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		".stversions/note~20240301-000000.md": "first\n",
+		"note.md":                             "first from original\n",
+		"note.sync-conflict-20240818-215425-I2NUVZU.md": "first from conflict\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(vault, filepath.FromSlash(name))
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	reporter, err := NewReporter(OutputJSON, &buf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resolver := &SyncConflictResolver{
+		finder:   &DefaultFileFinder{},
+		differ:   &mockDiffRunner{},
+		comparer: &DefaultFileComparer{},
+		mergers:  []Merger{&ThreeWayMerger{}},
+		reporter: reporter,
+		logger:   testr.New(t),
+	}
+	if err := resolver.ResolveSyncConflicts([]string{vault}, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var reports []ConflictReport
+	if err := json.Unmarshal(buf.Bytes(), &reports); err != nil {
+		t.Fatalf("Expected a JSON array, got %q: %v", buf.String(), err)
+	}
+	if len(reports) != 1 {
+		t.Fatalf("Expected 1 report, got %d", len(reports))
+	}
+	if reports[0].Action != ActionDiffering ||
+		!strings.HasPrefix(reports[0].Reason, "three-way: both sides changed lines 1-1 of ") {
+		t.Errorf("Expected a differing report with the overlap as reason, got %+v", reports[0])
+	}
+}
+
+func TestMoveFile(t *testing.T) {
+	dir := t.TempDir()
+	from := filepath.Join(dir, "note.md")
+	if err := os.WriteFile(from, []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := moveFile(from, filepath.Join(dir, "missing", "note.md")); err == nil {
+		t.Error("Expected an error moving into a missing directory, but got none")
+	}
+	if _, err := os.Stat(from); err != nil {
+		t.Errorf("Expected the file to stay in place: %v", err)
+	}
+
+	to := filepath.Join(dir, "moved.md")
+	if err := moveFile(from, to); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := os.Stat(from); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected %s to be gone, got %v", from, err)
+	}
+
+	if runtime.GOOS != "windows" && !crossDevice(&os.LinkError{Op: "rename", Err: syscall.EXDEV}) {
+		t.Error("Expected EXDEV to count as a cross-device rename")
+	}
+	for _, errno := range []syscall.Errno{syscall.EACCES, syscall.EBUSY, syscall.ENOENT} {
+		if crossDevice(&os.LinkError{Op: "rename", Err: errno}) {
+			t.Errorf("Expected %v not to count as a cross-device rename", errno)
+		}
+	}
+}
+
+type failingDisposer struct{}
+
+func (failingDisposer) Dispose(path string) (string, error) {
+	return "", errors.New("device busy")
+}
+
+func TestSyncConflictResolver_MergeKeepsConflict(t *testing.T) {
+	vault := t.TempDir()
+	if err := os.Mkdir(filepath.Join(vault, stversionsDir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	conflict := filepath.Join(vault, "note.sync-conflict-20240818-215425-I2NUVZU.md")
+	files := map[string]string{
+		".stversions/note~20240301-000000.md": "a\nb\nc\n",
+		"note.md":                             "A\nb\nc\n",
+		"note.sync-conflict-20240818-215425-I2NUVZU.md": "a\nb\nC\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(vault, filepath.FromSlash(name))
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	reporter, err := NewReporter(OutputJSON, &buf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resolver := &SyncConflictResolver{
+		finder:   &DefaultFileFinder{},
+		differ:   &mockDiffRunner{},
+		comparer: &DefaultFileComparer{},
+		mergers:  []Merger{&ThreeWayMerger{}},
+		ops:      &FileOps{Disposer: failingDisposer{}},
+		reporter: reporter,
+		logger:   testr.New(t),
+	}
+	if err := resolver.ResolveSyncConflicts([]string{vault}, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var reports []ConflictReport
+	if err := json.Unmarshal(buf.Bytes(), &reports); err != nil {
+		t.Fatalf("Expected a JSON array, got %q: %v", buf.String(), err)
+	}
+	if len(reports) != 1 {
+		t.Fatalf("Expected 1 report, got %d", len(reports))
+	}
+	if reports[0].Action != ActionMerged || !strings.Contains(reports[0].Error, "device busy") {
+		t.Errorf("Expected a merged report with the removal error, got %+v", reports[0])
+	}
+	if content, _ := os.ReadFile(filepath.Join(vault, "note.md")); string(content) != "A\nb\nC\n" {
+		t.Errorf("Expected the merge in the original, got %q", content)
+	}
+	if _, err := os.Stat(conflict); err != nil {
+		t.Errorf("Expected the conflict file to remain: %v", err)
+	}
+}
+
+func TestDisposers(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		goos     string
+		expected func(vault, state string) string
+	}{
+		{
+			name:     "delete",
+			strategy: DisposeDelete,
+			expected: func(vault, state string) string { return "" },
+		},
+		{
+			name:     "vault trash",
+			strategy: DisposeVaultTrash,
+			expected: func(vault, state string) string {
+				return filepath.Join(vault, ".trash", "note.md")
+			},
+		},
+		{
+			name:     "os trash",
+			strategy: DisposeOSTrash,
+			goos:     "linux",
+			expected: func(vault, state string) string {
+				return filepath.Join(state, "data", "Trash", "files", "note.md")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.goos != "" && runtime.GOOS != tt.goos {
+				t.Skipf("Only supported on %s", tt.goos)
+			}
+
+			vault := t.TempDir()
+			state := t.TempDir()
+			t.Setenv("XDG_DATA_HOME", filepath.Join(state, "data"))
+
+			if err := os.MkdirAll(filepath.Join(vault, ".obsidian", "plugins"), 0o755); err != nil {
+				t.Fatalf("Failed to create vault: %v", err)
+			}
+			if err := os.MkdirAll(filepath.Join(vault, "sub"), 0o755); err != nil {
+				t.Fatalf("Failed to create directory: %v", err)
+			}
+			path := filepath.Join(vault, "sub", "note.md")
+			if err := os.WriteFile(path, []byte("content"), 0o644); err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+
+			disposer, err := NewDisposer(tt.strategy, "")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			target, err := disposer.Dispose(path)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("Expected %s to be gone", path)
+			}
+
+			expected := tt.expected(vault, state)
+			if target != expected {
+				t.Fatalf("Expected target %s, got %s", expected, target)
+			}
+			if expected == "" {
+				return
+			}
+			content, err := os.ReadFile(expected)
+			if err != nil || string(content) != "content" {
+				t.Errorf("Expected disposed file at %s: %v", expected, err)
+			}
+		})
+	}
+}
+
+func TestQuarantineDisposer(t *testing.T) {
+	vault := t.TempDir()
+	quarantine := t.TempDir()
+
+	path := filepath.Join(vault, "note.md")
+	if err := os.WriteFile(path, []byte("content"), 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	disposer, err := NewDisposer(DisposeQuarantine, quarantine)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	target, err := disposer.Dispose(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	rel, err := filepath.Rel(quarantine, target)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		t.Fatalf("Expected %s to be inside %s", target, quarantine)
+	}
+	if !strings.HasSuffix(target, filepath.Join(filepath.Base(vault), "note.md")) {
+		t.Errorf("Expected %s to keep the original layout", target)
+	}
+	if _, err := os.Stat(target); err != nil {
+		t.Errorf("Expected quarantined file to exist: %v", err)
+	}
+}
+
+func TestNewDisposer_Unknown(t *testing.T) {
+	if _, err := NewDisposer("shred", ""); err == nil {
+		t.Error("Expected an error for an unknown strategy, but got none")
+	}
+}
+
+func TestJournalDir(t *testing.T) {
+	home, err := homedir.Dir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := journalDir("~/lessmay-journal")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if want := filepath.Join(home, "lessmay-journal"); dir != want {
+		t.Errorf("Expected %s, got %s", want, dir)
+	}
+}
+
+func TestUndo(t *testing.T) {
+	vault := t.TempDir()
+	journalDir := t.TempDir()
+
+	files := map[string]string{
+		"a.md": "a original\n",
+		"a.sync-conflict-20240818-215425-I2NUVZU.md": "a conflict\n",
+		"b.md": "b original\n",
+		"b.sync-conflict-20240818-215425-I2NUVZU.md": "b conflict\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(vault, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	ops, err := Options{JournalDir: journalDir}.fileOps(io.Discard)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	pairA, err := NewConflictPair(
+		filepath.Join(vault, "a.sync-conflict-20240818-215425-I2NUVZU.md"),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	pairB, err := NewConflictPair(
+		filepath.Join(vault, "b.sync-conflict-20240818-215425-I2NUVZU.md"),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := ops.keepConflict(pairA); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := ops.writeMerged(pairB, []byte("b merged\n")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	runs, err := JournalRuns(journalDir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(runs) != 1 || runs[0].Changes != 4 {
+		t.Fatalf("Expected one run with 4 changes, got %+v", runs)
+	}
+
+	err = Undo(testr.New(t), journalDir, "", false, io.Discard)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for name, expected := range files {
+		content, err := os.ReadFile(filepath.Join(vault, name))
+		if err != nil {
+			t.Errorf("Expected %s to be restored: %v", name, err)
+			continue
+		}
+		if string(content) != expected {
+			t.Errorf("Expected %s to contain %q, got %q", name, expected, content)
+		}
+	}
+
+	err = Undo(testr.New(t), journalDir, runs[0].ID, false, io.Discard)
+	if err == nil {
+		t.Error("Expected an error when undoing a run twice, but got none")
+	}
+}
+
+func TestNewWatcher_Output(t *testing.T) {
+	for _, output := range OutputFormats {
+		_, err := NewWatcher(testr.New(t), Options{JournalDir: t.TempDir(), Output: output}, nil, 0)
+		if wantErr := output == OutputJSON; (err != nil) != wantErr {
+			t.Errorf("Output %s: expected error %v, got %v", output, wantErr, err)
+		}
+	}
+}
+
+func TestWatcher_Run(t *testing.T) {
+	vault := t.TempDir()
+	if err := os.WriteFile(filepath.Join(vault, "note.md"), []byte("same\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write original file: %v", err)
+	}
+
+	watcher, err := NewWatcher(
+		testr.New(t),
+		Options{JournalDir: t.TempDir()},
+		nil,
+		50*time.Millisecond,
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- watcher.Run(ctx, []string{vault})
+	}()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}()
+
+	// Give the watcher time to register before the conflicts appear.
+	time.Sleep(100 * time.Millisecond)
+
+	subdir := filepath.Join(vault, "subdir")
+	if err := os.MkdirAll(subdir, 0o755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(subdir, "other.md"), []byte("x\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write original file: %v", err)
+	}
+
+	conflicts := []string{
+		filepath.Join(vault, "note.sync-conflict-20240818-215425-I2NUVZU.md"),
+		filepath.Join(subdir, "other.sync-conflict-20240818-215425-I2NUVZU.md"),
+	}
+	contents := []string{"same\n", "x\n"}
+	for i, conflict := range conflicts {
+		if err := os.WriteFile(conflict, []byte(contents[i]), 0o644); err != nil {
+			t.Fatalf("Failed to write conflict file: %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for _, conflict := range conflicts {
+		for {
+			if _, err := os.Stat(conflict); os.IsNotExist(err) {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected %s to be resolved", conflict)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+}
+
+func TestSyncConflictResolver_Reports(t *testing.T) {
+	tests := []struct {
+		name           string
+		comparerErr    error
+		deleted        bool
+		expectedAction string
+	}{
+		{
+			name:           "deleted identical",
+			deleted:        true,
+			expectedAction: ActionDeletedIdentical,
+		},
+		{
+			name:           "comparer error",
+			comparerErr:    errors.New("comparer error"),
+			expectedAction: ActionError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			reporter, err := NewReporter(OutputNDJSON, &buf)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			resolver := &SyncConflictResolver{
+				finder: &mockFileFinder{files: []string{
+					"/path/to/file.sync-conflict-20240818-215425-I2NUVZU.md",
+				}},
+				differ: &mockDiffRunner{},
+				comparer: &mockFileComparer{
+					deleted: tt.deleted,
+					err:     tt.comparerErr,
+				},
+				reporter: reporter,
+				logger:   testr.New(t),
+			}
+
+			if err := resolver.ResolveSyncConflicts([]string{"/test/path"}, nil); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var report ConflictReport
+			if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+				t.Fatalf("Expected a JSON record, got %q: %v", buf.String(), err)
+			}
+			if report.Action != tt.expectedAction {
+				t.Errorf("Expected action %s, got %s", tt.expectedAction, report.Action)
+			}
+			if report.OriginalPath != "/path/to/file.md" {
+				t.Errorf("Unexpected original path %s", report.OriginalPath)
+			}
+			if tt.comparerErr != nil && report.Error != tt.comparerErr.Error() {
+				t.Errorf("Expected error %q, got %q", tt.comparerErr, report.Error)
+			}
+		})
+	}
+}
+
+func TestNewReporter_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	reporter, err := NewReporter(OutputJSON, &buf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, action := range []string{ActionDiffering, ActionMerged} {
+		if err := reporter.Report(ConflictReport{Action: action}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := reporter.Flush(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var reports []ConflictReport
+	if err := json.Unmarshal(buf.Bytes(), &reports); err != nil {
+		t.Fatalf("Expected a JSON array, got %q: %v", buf.String(), err)
+	}
+	if len(reports) != 2 {
+		t.Errorf("Expected 2 reports, got %d", len(reports))
+	}
+
+	if _, err := NewReporter("xml", &buf); err == nil {
+		t.Error("Expected an error for an unknown format, but got none")
+	}
+}
+
+func TestDetectors(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"note.md", "plan.md"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		name             string
+		detector         ConflictDetector
+		file             string
+		expectOK         bool
+		expectedOriginal string
+		expectedDevice   string
+		expectedTime     time.Time
+	}{
+		{
+			name:             "syncthing",
+			detector:         &SyncthingDetector{},
+			file:             "note.sync-conflict-20240818-215425-I2NUVZU.md",
+			expectOK:         true,
+			expectedOriginal: "note.md",
+			expectedDevice:   "I2NUVZU",
+			expectedTime:     time.Date(2024, 8, 18, 21, 54, 25, 0, time.Local),
+		},
+		{
+			name:             "dropbox",
+			detector:         &DropboxDetector{},
+			file:             "note (Bob's conflicted copy 2024-08-18).md",
+			expectOK:         true,
+			expectedOriginal: "note.md",
+			expectedDevice:   "Bob",
+			expectedTime:     time.Date(2024, 8, 18, 0, 0, 0, 0, time.Local),
+		},
+		{
+			name:             "dropbox with counter",
+			detector:         &DropboxDetector{},
+			file:             "note (Bob's MacBook's conflicted copy 2024-08-18 (1)).md",
+			expectOK:         true,
+			expectedOriginal: "note.md",
+			expectedDevice:   "Bob's MacBook",
+			expectedTime:     time.Date(2024, 8, 18, 0, 0, 0, 0, time.Local),
+		},
+		{
+			name:             "nextcloud",
+			detector:         &NextcloudDetector{},
+			file:             "note (conflicted copy 2024-08-18 215425).md",
+			expectOK:         true,
+			expectedOriginal: "note.md",
+			expectedTime:     time.Date(2024, 8, 18, 21, 54, 25, 0, time.Local),
+		},
+		{
+			name:             "onedrive",
+			detector:         &OneDriveDetector{Hostnames: []string{"WORK-LAPTOP"}},
+			file:             "plan-work-laptop.md",
+			expectOK:         true,
+			expectedOriginal: "plan.md",
+			expectedDevice:   "WORK-LAPTOP",
+		},
+		{
+			name:             "onedrive with counter",
+			detector:         &OneDriveDetector{Hostnames: []string{"WORK-LAPTOP"}},
+			file:             "plan-WORK-LAPTOP-2.md",
+			expectOK:         true,
+			expectedOriginal: "plan.md",
+			expectedDevice:   "WORK-LAPTOP",
+		},
+		{
+			name:     "onedrive without original",
+			detector: &OneDriveDetector{Hostnames: []string{"WORK-LAPTOP"}},
+			file:     "ideas-WORK-LAPTOP.md",
+		},
+		{
+			name:             "google drive",
+			detector:         &GoogleDriveDetector{},
+			file:             "note (1).md",
+			expectOK:         true,
+			expectedOriginal: "note.md",
+		},
+		{
+			name:     "google drive without original",
+			detector: &GoogleDriveDetector{},
+			file:     "Chapter (1).md",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detection, ok := tt.detector.Detect(filepath.Join(dir, tt.file))
+			if ok != tt.expectOK {
+				t.Fatalf("Expected ok to be %v, got %v", tt.expectOK, ok)
+			}
+			if !ok {
+				return
+			}
+			expected := filepath.Join(dir, tt.expectedOriginal)
+			if detection.OriginalPath != expected {
+				t.Errorf("Expected original %s, got %s", expected, detection.OriginalPath)
+			}
+			if detection.DeviceID != tt.expectedDevice {
+				t.Errorf("Expected device %q, got %q", tt.expectedDevice, detection.DeviceID)
+			}
+			if !detection.Timestamp.Equal(tt.expectedTime) {
+				t.Errorf("Expected time %v, got %v", tt.expectedTime, detection.Timestamp)
+			}
+			if detection.Ext != ".md" {
+				t.Errorf("Expected extension .md, got %s", detection.Ext)
+			}
+		})
+	}
+}
+
+func TestDefaultFileFinder_VaultDetectors(t *testing.T) {
+	root := t.TempDir()
+	files := []string{
+		"dropbox/note.md",
+		"dropbox/note (Bob's conflicted copy 2024-08-18).md",
+		"dropbox/other.sync-conflict-20240818-215425-I2NUVZU.md",
+		"syncthing/note.sync-conflict-20240818-215425-I2NUVZU.md",
+		"syncthing/note (Bob's conflicted copy 2024-08-18).md",
+	}
+	for _, file := range files {
+		path := filepath.Join(root, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", file, err)
+		}
+	}
+
+	finder, err := Options{
+		VaultDetectors: map[string][]string{
+			filepath.Join(root, "dropbox"): {DetectorDropbox},
+		},
+	}.fileFinder(logr.Discard())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	pairs, err := finder.FindSyncConflictFiles([]string{root}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		filepath.Join(root, "dropbox", "note (Bob's conflicted copy 2024-08-18).md"):      DetectorDropbox,
+		filepath.Join(root, "syncthing", "note.sync-conflict-20240818-215425-I2NUVZU.md"): DetectorSyncthing,
+	}
+	if len(pairs) != len(expected) {
+		t.Fatalf("Expected %d pairs, got %d: %+v", len(expected), len(pairs), pairs)
+	}
+	for _, pair := range pairs {
+		detector, ok := expected[pair.ConflictPath]
+		if !ok {
+			t.Errorf("Unexpected conflict %s", pair.ConflictPath)
+			continue
+		}
+		if pair.Detector != detector {
+			t.Errorf("Expected detector %s for %s, got %s", detector, pair.ConflictPath, pair.Detector)
+		}
+	}
+
+	if _, err := (Options{Detectors: []string{"icloud"}}).fileFinder(logr.Discard()); err == nil {
+		t.Error("Expected an error for an unknown detector, but got none")
+	}
+}
+
+func TestSyncConflictResolver_Orphans(t *testing.T) {
+	const conflictName = "note.sync-conflict-20240818-215425-I2NUVZU.md"
+
+	tests := []struct {
+		name           string
+		action         string
+		renamedExists  bool
+		expectedAction string
+		expectConflict bool
+		expectOriginal bool
+	}{
+		{
+			name:           "report",
+			action:         OrphanReport,
+			expectedAction: ActionOrphaned,
+			expectConflict: true,
+		},
+		{
+			name:           "promote",
+			action:         OrphanPromote,
+			expectedAction: ActionPromoted,
+			expectOriginal: true,
+		},
+		{
+			name:           "find renamed",
+			action:         OrphanFindRenamed,
+			renamedExists:  true,
+			expectedAction: ActionFoundRenamed,
+		},
+		{
+			name:           "find renamed without match",
+			action:         OrphanFindRenamed,
+			expectedAction: ActionOrphaned,
+			expectConflict: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vault := t.TempDir()
+			conflictFile := filepath.Join(vault, conflictName)
+			if err := os.WriteFile(conflictFile, []byte("moved note\n"), 0o644); err != nil {
+				t.Fatalf("Failed to write conflict file: %v", err)
+			}
+			renamed := filepath.Join(vault, "archive", "renamed note.md")
+			if tt.renamedExists {
+				if err := os.MkdirAll(filepath.Dir(renamed), 0o755); err != nil {
+					t.Fatalf("Failed to create directory: %v", err)
+				}
+				if err := os.WriteFile(renamed, []byte("moved note\n"), 0o644); err != nil {
+					t.Fatalf("Failed to write renamed file: %v", err)
+				}
+			}
+
+			var buf bytes.Buffer
+			reporter, err := NewReporter(OutputNDJSON, &buf)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			resolver := &SyncConflictResolver{
+				finder:       &DefaultFileFinder{},
+				differ:       &mockDiffRunner{},
+				comparer:     &DefaultFileComparer{},
+				reporter:     reporter,
+				orphanAction: tt.action,
+				logger:       testr.New(t),
+			}
+
+			if err := resolver.ResolveSyncConflicts([]string{vault}, nil); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var report ConflictReport
+			if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+				t.Fatalf("Expected a JSON record, got %q: %v", buf.String(), err)
+			}
+			if report.Action != tt.expectedAction {
+				t.Errorf("Expected action %s, got %s (%s)", tt.expectedAction, report.Action, report.Error)
+			}
+			if tt.renamedExists && report.RenamedTo != renamed {
+				t.Errorf("Expected renamedTo %s, got %s", renamed, report.RenamedTo)
+			}
+
+			_, err = os.Stat(conflictFile)
+			if exists := err == nil; exists != tt.expectConflict {
+				t.Errorf("Expected conflict file to exist: %v, got %v", tt.expectConflict, exists)
+			}
+			_, err = os.Stat(filepath.Join(vault, "note.md"))
+			if exists := err == nil; exists != tt.expectOriginal {
+				t.Errorf("Expected original to exist: %v, got %v", tt.expectOriginal, exists)
+			}
+		})
+	}
+}
+
+func TestSyncConflictResolver_ChainedConflicts(t *testing.T) {
+	vault := t.TempDir()
+	first := filepath.Join(vault, "note.sync-conflict-20240818-215425-AAAA.md")
+	second := filepath.Join(
+		vault,
+		"note.sync-conflict-20240818-215425-AAAA.sync-conflict-20240819-101010-BBBB.md",
+	)
+	other := filepath.Join(vault, "note.sync-conflict-20240820-080000-CCCC.md")
+	files := map[string]string{
+		filepath.Join(vault, "note.md"): "same\n",
+		first:                           "same\n",
+		second:                          "edited on BBBB\n",
+		other:                           "edited on CCCC\n",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	pairs, err := (&DefaultFileFinder{}).FindSyncConflictFiles([]string{vault}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	groups := GroupConflicts(pairs)
+	if len(groups) != 1 {
+		t.Fatalf("Expected 1 group, got %d", len(groups))
+	}
+	var order []string
+	for _, pair := range groups[0].Pairs {
+		order = append(order, filepath.Base(pair.ConflictPath))
+	}
+	expected := []string{filepath.Base(first), filepath.Base(other), filepath.Base(second)}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected order %v, got %v", expected, order)
+	}
+	if last := groups[0].Pairs[2]; last.Generation != 2 || last.Parent != first {
+		t.Errorf("Expected generation 2 with parent %s, got %d and %s", first, last.Generation, last.Parent)
+	}
+
+	var buf bytes.Buffer
+	reporter, err := NewReporter(OutputJSON, &buf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resolver := &SyncConflictResolver{
+		finder:   &DefaultFileFinder{},
+		differ:   &mockDiffRunner{},
+		comparer: &DefaultFileComparer{},
+		reporter: reporter,
+		logger:   testr.New(t),
+	}
+	if err := resolver.ResolveSyncConflicts([]string{vault}, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var reports []ConflictReport
+	if err := json.Unmarshal(buf.Bytes(), &reports); err != nil {
+		t.Fatalf("Expected a JSON array, got %q: %v", buf.String(), err)
+	}
+	actions := map[string]string{}
+	for _, report := range reports {
+		if report.OriginalPath != filepath.Join(vault, "note.md") {
+			t.Errorf("Expected every generation to compare against note.md, got %s", report.OriginalPath)
+		}
+		actions[report.ConflictPath] = report.Action
+	}
+	if actions[first] != ActionDeletedIdentical {
+		t.Errorf("Expected first generation to be deleted, got %s", actions[first])
+	}
+	if actions[second] != ActionDiffering || actions[other] != ActionDiffering {
+		t.Errorf("Expected later copies to differ, got %v", actions)
+	}
+}
+
+func TestGroupView(t *testing.T) {
+	vault := t.TempDir()
+	original := filepath.Join(vault, "note.md")
+	copies := []string{
+		filepath.Join(vault, "note.sync-conflict-20240818-215425-AAAA.md"),
+		filepath.Join(vault, "note.sync-conflict-20240819-101010-BBBB.md"),
+		filepath.Join(vault, "note.sync-conflict-20240820-080000-CCCC.md"),
+	}
+	contents := map[string]string{
+		original:  "a\nb\n",
+		copies[0]: "a\nb\nc\n",
+		copies[1]: "a\nB\n",
+		copies[2]: "a\nb\nc\n",
+	}
+	now := time.Now()
+	for i, path := range append([]string{original}, copies...) {
+		if err := os.WriteFile(path, []byte(contents[path]), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+		mtime := now.Add(time.Duration(i) * time.Minute)
+		if path == copies[1] {
+			mtime = now.Add(time.Hour)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("Failed to set mtime: %v", err)
+		}
+	}
+
+	pairs, err := (&DefaultFileFinder{}).FindSyncConflictFiles([]string{vault}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	view, err := NewGroupView(GroupConflicts(pairs)[0], diff.Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(view.Variants) != 4 {
+		t.Fatalf("Expected 4 variants, got %d", len(view.Variants))
+	}
+	if view.Variants[3].SameAs != 1 {
+		t.Errorf("Expected CCCC to match AAAA, got SameAs %d", view.Variants[3].SameAs)
+	}
+	if !view.Variants[2].Newest {
+		t.Error("Expected BBBB to be newest")
+	}
+	if got := view.IdenticalTo(1); !reflect.DeepEqual(got, []string{copies[2]}) {
+		t.Errorf("Expected AAAA to be identical to CCCC, got %v", got)
+	}
+	if !reflect.DeepEqual(view.Columns, []int{0, 1, 2}) {
+		t.Errorf("Expected columns [0 1 2], got %v", view.Columns)
+	}
+
+	var out bytes.Buffer
+	if err := view.Write(&out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, want := range []string{"= [1]", "newest", "| c", "| B"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected view to contain %q, got:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "| a") {
+		t.Errorf("Expected lines shared by every variant to be left out, got:\n%s", out.String())
+	}
+}
+
+func TestInteractiveResolver_Group(t *testing.T) {
+	tests := []struct {
+		name             string
+		input            string
+		expectedOriginal string
+		expectRemaining  int
+	}{
+		{
+			name:             "keep original",
+			input:            "0\n",
+			expectedOriginal: "original\n",
+		},
+		{
+			name:             "keep a conflict copy",
+			input:            "2\n",
+			expectedOriginal: "second\n",
+		},
+		{
+			name:             "one at a time",
+			input:            "\no\ns\n",
+			expectedOriginal: "original\n",
+			expectRemaining:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vault := t.TempDir()
+			originalFile := filepath.Join(vault, "note.md")
+			files := map[string]string{
+				originalFile: "original\n",
+				filepath.Join(vault, "note.sync-conflict-20240818-215425-AAAA.md"): "first\n",
+				filepath.Join(vault, "note.sync-conflict-20240819-101010-BBBB.md"): "second\n",
+			}
+			for path, content := range files {
+				if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+					t.Fatalf("Failed to write %s: %v", path, err)
+				}
+			}
+
+			var out bytes.Buffer
+			resolver, err := NewInteractiveResolver(
+				testr.New(t),
+				Options{JournalDir: t.TempDir()},
+				strings.NewReader(tt.input),
+				&out,
+			)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if err := resolver.Resolve([]string{vault}, nil); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			content, err := os.ReadFile(originalFile)
+			if err != nil {
+				t.Fatalf("Failed to read original: %v", err)
+			}
+			if string(content) != tt.expectedOriginal {
+				t.Errorf("Expected original %q, got %q", tt.expectedOriginal, content)
+			}
+			remaining, _ := filepath.Glob(filepath.Join(vault, "*.sync-conflict-*"))
+			if len(remaining) != tt.expectRemaining {
+				t.Errorf("Expected %d conflict copies left, got %v", tt.expectRemaining, remaining)
+			}
+		})
+	}
+}
+
+func TestStignore(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, ".stfolder"), 0o755); err != nil {
+		t.Fatalf("Failed to create .stfolder: %v", err)
+	}
+	stignore := strings.Join([]string{
+		"// comment",
+		"#include shared/ignores.txt",
+		"!/keep/important.md",
+		"/keep",
+		"(?i)*.TMP",
+		"build",
+		"/top-only.md",
+		"docs/**/draft-*",
+		"*.{log,bak}",
+	}, "\n")
+	if err := os.WriteFile(filepath.Join(root, StignoreFile), []byte(stignore), 0o644); err != nil {
+		t.Fatalf("Failed to write .stignore: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "shared"), 0o755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "shared", "ignores.txt"), []byte("from-include\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write include: %v", err)
+	}
+
+	ignore, err := LoadStignore(filepath.Join(root, "notes"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		path    string
+		ignored bool
+	}{
+		{"note.md", false},
+		{"from-include", true},
+		{"notes/from-include/a.md", true},
+		{"keep/important.md", false},
+		{"keep/other.md", true},
+		{"scratch.tmp", true},
+		{"a/b/SCRATCH.TMP", true},
+		{"build/out.md", true},
+		{"src/build/out.md", true},
+		{"builder/out.md", false},
+		{"top-only.md", true},
+		{"sub/top-only.md", false},
+		{"docs/a/b/draft-1.md", true},
+		{"docs/final.md", false},
+		{"x/debug.log", true},
+		{"x/note.bak", true},
+		{".stversions/note~20240818-215425.md", true},
+		{"sub/.stversions/note.md", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got := ignore.Match(filepath.Join(root, filepath.FromSlash(tt.path)))
+			if got != tt.ignored {
+				t.Errorf("Expected ignored to be %v, got %v", tt.ignored, got)
+			}
+		})
+	}
+
+	if ignore.Match(filepath.Join(t.TempDir(), "build")) {
+		t.Error("Expected paths outside the folder not to be ignored")
+	}
+	if ignore.canSkipDir() {
+		t.Error("Expected negated patterns to prevent skipping directories")
+	}
+
+	missing, err := LoadStignore(t.TempDir())
+	if err != nil || missing != nil {
+		t.Errorf("Expected no matcher without a folder, got %v, %v", missing, err)
+	}
+
+	noFile := t.TempDir()
+	if err := os.Mkdir(filepath.Join(noFile, ".stfolder"), 0o755); err != nil {
+		t.Fatalf("Failed to create .stfolder: %v", err)
+	}
+	if empty, err := LoadStignore(noFile); err != nil || empty == nil {
+		t.Errorf("Expected a matcher without .stignore, got %v, %v", empty, err)
+	}
+
+	missingInclude := "first\n#include missing.txt\nsecret\n"
+	if err := os.WriteFile(filepath.Join(noFile, StignoreFile), []byte(missingInclude), 0o644); err != nil {
+		t.Fatalf("Failed to write .stignore: %v", err)
+	}
+	if _, err := LoadStignore(noFile); err == nil {
+		t.Error("Expected an error for a missing #include file, but got none")
+	}
+}
+
+func TestDefaultFileFinder_Stignore(t *testing.T) {
+	root := t.TempDir()
+	conflict := "note.sync-conflict-20240818-215425-I2NUVZU.md"
+	for _, dir := range []string{"", "ignored", "kept"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(root, dir, "note.md"), []byte("a\n"), 0o644); err != nil {
+			t.Fatalf("Failed to write original: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(root, dir, conflict), []byte("b\n"), 0o644); err != nil {
+			t.Fatalf("Failed to write conflict: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, StignoreFile), []byte("/ignored\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write .stignore: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		noStignore bool
+		expected   int
+	}{
+		{name: "honour .stignore", expected: 2},
+		{name: "no-stignore", noStignore: true, expected: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			finder := &DefaultFileFinder{NoStignore: tt.noStignore}
+			pairs, err := finder.FindSyncConflictFiles([]string{root}, nil)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(pairs) != tt.expected {
+				t.Errorf("Expected %d conflicts, got %d", tt.expected, len(pairs))
+			}
+			for _, pair := range pairs {
+				if !tt.noStignore && strings.Contains(pair.ConflictPath, "ignored") {
+					t.Errorf("Expected %s to be ignored", pair.ConflictPath)
+				}
+			}
+		})
+	}
+}
+
+func TestPathFilter(t *testing.T) {
+	root := t.TempDir()
+
+	tests := []struct {
+		name        string
+		skipPaths   []string
+		skipRegexes []string
+		include     []string
+		path        string
+		isDir       bool
+		expectSkip  bool
+	}{
+		{name: "name at any depth", skipPaths: []string{".trash"}, path: "notes/.trash/a.md", expectSkip: true},
+		{name: "name is not a substring", skipPaths: []string{".trash"}, path: "notes/.trashcan-ideas/a.md"},
+		{name: "file name containing skip path", skipPaths: []string{"trash"}, path: "notes/trash-talk.md"},
+		{name: "anchored at root", skipPaths: []string{"/archive"}, path: "archive/a.md", expectSkip: true},
+		{name: "anchored does not match deeper", skipPaths: []string{"/archive"}, path: "notes/archive/a.md"},
+		{name: "middle slash anchors", skipPaths: []string{"notes/old"}, path: "notes/old/a.md", expectSkip: true},
+		{name: "middle slash anchored deeper", skipPaths: []string{"notes/old"}, path: "x/notes/old/a.md"},
+		{name: "double star", skipPaths: []string{"**/drafts/*.md"}, path: "a/b/drafts/x.md", expectSkip: true},
+		{name: "double star matches none", skipPaths: []string{"a/**/x.md"}, path: "a/x.md", expectSkip: true},
+		{name: "extension glob", skipPaths: []string{"*.png"}, path: "img/a.png", expectSkip: true},
+		{name: "directory only skips directory", skipPaths: []string{"build/"}, path: "build", isDir: true, expectSkip: true},
+		{name: "directory only ignores file", skipPaths: []string{"build/"}, path: "build"},
+		{name: "directory only skips contents", skipPaths: []string{"build/"}, path: "build/a.md", expectSkip: true},
+		{name: "regex", skipRegexes: []string{`(^|/)tmp-\d+`}, path: "x/tmp-123/a.md", expectSkip: true},
+		{name: "regex no match", skipRegexes: []string{`(^|/)tmp-\d+`}, path: "x/tmp-abc/a.md"},
+		{name: "include match", include: []string{"daily/**"}, path: "daily/2024/a.md"},
+		{name: "include miss", include: []string{"daily/**"}, path: "notes/a.md", expectSkip: true},
+		{name: "include leaves directories", include: []string{"*.md"}, path: "notes", isDir: true},
+		{name: "skip beats include", skipPaths: []string{".trash"}, include: []string{"*.md"}, path: ".trash/a.md", expectSkip: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			regexes, err := compileRegexes(tt.skipRegexes)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			finder := &DefaultFileFinder{SkipRegexes: regexes, Include: tt.include}
+			filter, err := finder.filter(root, tt.skipPaths)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			skip, _ := filter.check(filepath.Join(root, filepath.FromSlash(tt.path)), tt.isDir)
+			if skip != tt.expectSkip {
+				t.Errorf("Expected skip to be %v, got %v", tt.expectSkip, skip)
+			}
+		})
+	}
+
+	if _, err := compileRegexes([]string{"("}); err == nil {
+		t.Error("Expected an error for an invalid regex, but got none")
+	}
+	if _, err := compileGlob("[abc"); err == nil {
+		t.Error("Expected an error for an invalid glob, but got none")
+	}
+}
+
+func TestDeviceNames(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.xml")
+	config := `<configuration version="37">
+    <folder id="notes" label="Notes" path="/home/me/Notes" type="sendreceive">
+        <device id="I2NUVZU-ABCDEFG-HIJKLMN-OPQRSTU-VWXYZ23-4567ABC-DEFGHIJ-KLMNOPQ"></device>
+    </folder>
+    <device id="I2NUVZU-ABCDEFG-HIJKLMN-OPQRSTU-VWXYZ23-4567ABC-DEFGHIJ-KLMNOPQ" name="work-laptop" compression="metadata"></device>
+    <device id="P56IOI7-MZJNU2Y-IQGDREY-DM2MGTI-MGL3BXN-PQ6W5BM-TBBZ4TJ-XZWICQ2" name="phone"></device>
+    <device id="AAAAAAA-BBBBBBB-CCCCCCC-DDDDDDD-EEEEEEE-FFFFFFF-GGGGGGG-HHHHHHH" name=""></device>
+</configuration>`
+	if err := os.WriteFile(configFile, []byte(config), 0o644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	syncthingConfig, err := loadSyncthingConfig(configFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Config keys arrive lower-cased from viper.
+	names := NewDeviceNames(syncthingConfig, map[string]string{"p56ioi7": "old-phone"})
+
+	tests := []struct {
+		id       string
+		expected string
+	}{
+		{"I2NUVZU", "work-laptop"},
+		{"i2nuvzu", "work-laptop"},
+		{"P56IOI7", "old-phone"},
+		{"AAAAAAA", ""},
+		{"ZZZZZZZ", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := names.Name(tt.id); got != tt.expected {
+			t.Errorf("Expected %q for %q, got %q", tt.expected, tt.id, got)
+		}
+	}
+
+	if _, err := loadSyncthingConfig(filepath.Join(dir, "missing.xml")); err == nil {
+		t.Error("Expected an error for a missing explicit config, but got none")
+	}
+
+	conflictFile := filepath.Join(dir, "note.sync-conflict-20240818-215425-I2NUVZU.md")
+	for _, path := range []string{conflictFile, filepath.Join(dir, "note.md")} {
+		if err := os.WriteFile(path, []byte(path), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	var buf bytes.Buffer
+	reporter, err := NewReporter(OutputNDJSON, &buf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resolver := &SyncConflictResolver{
+		finder:   &DefaultFileFinder{DeviceNames: names},
+		differ:   &mockDiffRunner{},
+		comparer: &DefaultFileComparer{},
+		reporter: reporter,
+		logger:   testr.New(t),
+	}
+	if err := resolver.ResolveSyncConflicts([]string{dir}, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var report ConflictReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("Expected a JSON record, got %q: %v", buf.String(), err)
+	}
+	if report.DeviceID != "I2NUVZU" || report.DeviceName != "work-laptop" {
+		t.Errorf("Expected device I2NUVZU/work-laptop, got %s/%s", report.DeviceID, report.DeviceName)
+	}
+}
+
+func TestSyncthingClient(t *testing.T) {
+	vault := t.TempDir()
+	nested := filepath.Join(vault, "nested")
+	if err := os.MkdirAll(filepath.Join(vault, "notes"), 0o755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	var scans []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "secret" {
+			http.Error(w, "CSRF Error", http.StatusForbidden)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/rest/config/folders":
+			folders := []SyncthingFolder{
+				{ID: "notes", Label: "Notes", Path: vault},
+				{ID: "nested", Label: "Nested", Path: nested},
+			}
+			if err := json.NewEncoder(w).Encode(folders); err != nil {
+				t.Errorf("Failed to encode folders: %v", err)
+			}
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/db/scan":
+			scans = append(scans, r.URL.RawQuery)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewSyncthingClient(server.URL, "secret", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	folders, err := client.Folders()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(folders) != 2 || folders[0].Path != vault {
+		t.Errorf("Expected the two folders, got %+v", folders)
+	}
+
+	err = client.Rescan([]string{
+		filepath.Join(vault, "notes", "a.md"),
+		filepath.Join(vault, "notes", "b.md"),
+		filepath.Join(nested, "c.md"),
+		filepath.Join(t.TempDir(), "outside.md"),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{"folder=nested", "folder=notes&sub=notes"}
+	if !reflect.DeepEqual(scans, expected) {
+		t.Errorf("Expected scans %v, got %v", expected, scans)
+	}
+
+	bad, err := NewSyncthingClient(server.URL, "wrong", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := bad.Folders(); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Expected a 403 error, got %v", err)
+	}
+
+	// The resolver rescans after deleting an identical conflict copy.
+	scans = nil
+	conflictFile := filepath.Join(vault, "notes", "n.sync-conflict-20240818-215425-AAAA.md")
+	for _, path := range []string{conflictFile, filepath.Join(vault, "notes", "n.md")} {
+		if err := os.WriteFile(path, []byte("same\n"), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	ops := &FileOps{Out: io.Discard}
+	resolver := &SyncConflictResolver{
+		finder:   &DefaultFileFinder{},
+		differ:   &mockDiffRunner{},
+		comparer: &DefaultFileComparer{Ops: ops},
+		ops:      ops,
+		rescan:   client,
+		logger:   testr.New(t),
+	}
+	if err := resolver.ResolveSyncConflicts([]string{vault}, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(scans, []string{"folder=notes&sub=notes"}) {
+		t.Errorf("Expected a rescan of notes, got %v", scans)
+	}
+}
+
+func TestNewSyncthingClient_Address(t *testing.T) {
+	tests := []struct {
+		address  string
+		tls      bool
+		expected string
+	}{
+		{"", false, "http://127.0.0.1:8384"},
+		{"0.0.0.0:8384", true, "https://127.0.0.1:8384"},
+		{"[::]:8384", false, "http://127.0.0.1:8384"},
+		{"http://example.com:8384/", false, "http://example.com:8384"},
+	}
+	for _, tt := range tests {
+		client, err := NewSyncthingClient(tt.address, "key", tt.tls)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if client.BaseURL != tt.expected {
+			t.Errorf("Expected %s for %q, got %s", tt.expected, tt.address, client.BaseURL)
+		}
+	}
+}
+
+func TestOptions_SyncthingClientFromConfig(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.xml")
+	config := `<configuration>
+    <gui enabled="true" tls="true">
+        <address>0.0.0.0:8385</address>
+        <apikey>from-config</apikey>
+    </gui>
+</configuration>`
+	if err := os.WriteFile(configFile, []byte(config), 0o644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	client, err := Options{Syncthing: true, SyncthingConfig: configFile}.syncthingClient()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if client.BaseURL != "https://127.0.0.1:8385" || client.APIKey != "from-config" {
+		t.Errorf("Expected settings from config.xml, got %s %s", client.BaseURL, client.APIKey)
+	}
+
+	client, err = Options{
+		Syncthing:       true,
+		SyncthingConfig: configFile,
+		SyncthingAPIKey: "explicit",
+	}.syncthingClient()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if client.APIKey != "explicit" {
+		t.Errorf("Expected the explicit API key to win, got %s", client.APIKey)
+	}
+
+	if client, err := (Options{}).syncthingClient(); client != nil || err != nil {
+		t.Errorf("Expected no client when the API is off, got %v, %v", client, err)
+	}
+}
+
+func TestFindObsidianVaults(t *testing.T) {
+	dir := t.TempDir()
+	work := filepath.Join(dir, "Work Notes")
+	personal := filepath.Join(dir, "personal")
+	registry := fmt.Sprintf(`{
+  "vaults": {
+    "a1b2c3d4e5f6a7b8": {"path": %q, "ts": 1724012345678, "open": true},
+    "0f9e8d7c6b5a4938": {"path": %q, "ts": 1724000000000}
+  },
+  "frame": "hidden"
+}`, work, personal)
+	configFile := filepath.Join(dir, "obsidian.json")
+	if err := os.WriteFile(configFile, []byte(registry), 0o644); err != nil {
+		t.Fatalf("Failed to write registry: %v", err)
+	}
+	// personal was deleted on disk but is still registered.
+	if err := os.Mkdir(work, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	vaults, err := FindObsidianVaults(configFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(vaults) != 2 {
+		t.Fatalf("Expected 2 vaults, got %d", len(vaults))
+	}
+	if vaults[0].Name != "Work Notes" || !vaults[0].Open || vaults[0].Missing ||
+		vaults[1].Name != "personal" || !vaults[1].Missing {
+		t.Errorf("Expected Work Notes (open) then personal (missing), got %+v", vaults)
+	}
+
+	tests := []struct {
+		name        string
+		args        []string
+		defaultPath string
+		vaults      []string
+		expected    []string
+		expectErr   bool
+	}{
+		{name: "arguments win", args: []string{"/tmp/x"}, vaults: []string{"personal"}, expected: []string{"/tmp/x"}},
+		{name: "vault by name", vaults: []string{"work notes"}, expected: []string{work}},
+		{name: "vault by id", vaults: []string{"0f9e8d7c6b5a4938"}, expected: []string{personal}},
+		{name: "unknown vault", vaults: []string{"nope"}, expectErr: true},
+		{name: "default path", defaultPath: "/tmp/vault", expected: []string{"/tmp/vault"}},
+		{name: "all registered vaults that exist", expected: []string{work}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{ObsidianConfig: configFile, Vaults: tt.vaults}
+			paths, err := getConflictPaths(testr.New(t), tt.args, tt.defaultPath, opts)
+			if tt.expectErr {
+				if err == nil {
+					t.Error("Expected an error, but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(paths, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, paths)
+			}
+		})
+	}
+
+	if _, err := FindObsidianVaults(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("Expected an error for a missing explicit registry, but got none")
+	}
+
+	malformed := filepath.Join(dir, "malformed.json")
+	if err := os.WriteFile(malformed, []byte("{"), 0o644); err != nil {
+		t.Fatalf("Failed to write registry: %v", err)
+	}
+	uris, err := Options{ObsidianConfig: malformed}.uriBuilder(testr.New(t))
+	if err != nil {
+		t.Fatalf("Expected a malformed registry not to fail the run, got %v", err)
+	}
+	if uri := uris.URI(filepath.Join(work, "a.md")); !strings.HasPrefix(uri, "obsidian://open?path=") {
+		t.Errorf("Expected the path form without a registry, got %s", uri)
+	}
+}
+
+func TestURIBuilder(t *testing.T) {
+	dir := t.TempDir()
+	registered := filepath.Join(dir, "My Vault")
+	unregistered := filepath.Join(dir, "Other")
+	if err := os.MkdirAll(filepath.Join(unregistered, ".obsidian"), 0o755); err != nil {
+		t.Fatalf("Failed to create .obsidian: %v", err)
+	}
+	vaults := []ObsidianVault{{Name: "My Vault", Path: registered}}
+
+	tests := []struct {
+		name     string
+		form     string
+		path     string
+		expected string
+	}{
+		{
+			name:     "registered vault",
+			path:     filepath.Join(registered, "Daily", "2024-08-18 #1 & café.md"),
+			expected: "obsidian://open?vault=My%20Vault&file=Daily%2F2024-08-18%20%231%20%26%20caf%C3%A9",
+		},
+		{
+			name:     "attachment keeps its extension",
+			path:     filepath.Join(registered, "img.png"),
+			expected: "obsidian://open?vault=My%20Vault&file=img.png",
+		},
+		{
+			name:     "vault found by .obsidian folder",
+			path:     filepath.Join(unregistered, "a b.md"),
+			expected: "obsidian://open?vault=Other&file=a%20b",
+		},
+		{
+			name:     "outside any vault",
+			path:     filepath.Join(dir, "loose+note.md"),
+			expected: "obsidian://open?path=" + encodeURIComponent(filepath.ToSlash(filepath.Join(dir, "loose+note.md"))),
+		},
+		{
+			name:     "path form",
+			form:     URIFormPath,
+			path:     filepath.Join(registered, "a b.md"),
+			expected: "obsidian://open?path=" + encodeURIComponent(filepath.ToSlash(filepath.Join(registered, "a b.md"))),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder, err := NewURIBuilder(tt.form, vaults)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := builder.URI(tt.path); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+
+	if got := encodeURIComponent("/a b+c"); got != "%2Fa%20b%2Bc" {
+		t.Errorf("Expected RFC 3986 encoding, got %s", got)
+	}
+	if _, err := NewURIBuilder("bogus", nil); err == nil {
+		t.Error("Expected an error for an unknown URI form, but got none")
+	}
+}
+
+func TestOpener(t *testing.T) {
+	opener := NewOpener("flatpak run md.obsidian.Obsidian")
+	if !reflect.DeepEqual(opener.Command, []string{"flatpak", "run", "md.obsidian.Obsidian"}) {
+		t.Errorf("Expected the configured command, got %v", opener.Command)
+	}
+	if runtime.GOOS != "windows" {
+		got := opener.CommandLine("obsidian://open?vault=V&file=it's")
+		expected := `flatpak run md.obsidian.Obsidian 'obsidian://open?vault=V&file=it'\''s'`
+		if got != expected {
+			t.Errorf("Expected %s, got %s", expected, got)
+		}
+	}
+
+	expected := map[string]string{"darwin": "open", "windows": "rundll32"}[runtime.GOOS]
+	if expected == "" {
+		expected = "xdg-open"
+	}
+	if got := NewOpener("").Command[0]; got != expected {
+		t.Errorf("Expected default opener %s, got %s", expected, got)
+	}
+}
+
+func TestEquivalent(t *testing.T) {
+	nfc := "caf\u00e9\n"
+	nfd := "cafe\u0301\n"
+
+	tests := []struct {
+		name  string
+		a     string
+		b     string
+		least string
+	}{
+		{name: "identical", a: "a\nb\n", b: "a\nb\n", least: EquivalenceExact},
+		{name: "crlf", a: "a\r\nb\r\n", b: "a\nb\n", least: EquivalenceNewlines},
+		{name: "bom", a: "\ufeffa\n", b: "a\n", least: EquivalenceNewlines},
+		{name: "final newline", a: "a\nb", b: "a\nb\n", least: EquivalenceNewlines},
+		{name: "extra blank lines", a: "a\n", b: "a\n\n\n\n", least: EquivalenceWhitespace},
+		{name: "crlf final newline", a: "a\r\n", b: "a", least: EquivalenceNewlines},
+		{name: "trailing spaces", a: "a  \nb\t\n", b: "a\nb\n", least: EquivalenceTrailingSpace},
+		{name: "nfc vs nfd", a: nfc, b: nfd, least: EquivalenceUnicode},
+		{name: "reflowed", a: "a b\nc\n", b: "ab c\n", least: EquivalenceWhitespace},
+		{name: "different words", a: "a\n", b: "b\n"},
+		{name: "binary", a: "a\x00\r\n", b: "a\x00\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			least := len(EquivalenceLevels)
+			if tt.least != "" {
+				least, _ = equivalenceRank(tt.least)
+			}
+			for rank, level := range EquivalenceLevels {
+				got, err := equivalent([]byte(tt.a), []byte(tt.b), level)
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if want := rank >= least; got != want {
+					t.Errorf("At level %s expected %v, got %v", level, want, got)
+				}
+			}
+		})
+	}
+
+	if _, err := equivalent([]byte("a"), []byte("b"), "fuzzy"); err == nil {
+		t.Error("Expected an error for an unknown level, but got none")
+	}
+}
+
+func TestNotesEquivalent(t *testing.T) {
+	tests := []struct {
+		name  string
+		a     string
+		b     string
+		level string
+		want  bool
+	}{
+		{
+			name: "key order and ignored keys",
+			a:    "---\ntitle: Note\ntags: [a, b]\nupdated: 2024-08-18\n---\nbody\n",
+			b:    "---\ntags:\n  - a\n  - b\ntitle: Note\nupdated: 2024-08-19\n---\nbody\n",
+			want: true,
+		},
+		{
+			name: "meaningful key changed",
+			a:    "---\ntitle: Note\n---\nbody\n",
+			b:    "---\ntitle: Other\n---\nbody\n",
+		},
+		{
+			name: "ignored key added",
+			a:    "---\ntitle: Note\n---\nbody\n",
+			b:    "---\ntitle: Note\nModified: today\n---\nbody\n",
+			want: true,
+		},
+		{
+			name: "body changed",
+			a:    "---\ntitle: Note\n---\nbody\n",
+			b:    "---\ntitle: Note\n---\nother\n",
+		},
+		{
+			name:  "body compared at level",
+			a:     "---\ntitle: Note\nupdated: 1\n---\r\nbody\r\n",
+			b:     "---\ntitle: Note\nupdated: 2\n---\nbody\n",
+			level: EquivalenceNewlines,
+			want:  true,
+		},
+		{
+			name: "no frontmatter",
+			a:    "body\n",
+			b:    "body\n",
+			want: true,
+		},
+		{
+			name: "invalid frontmatter",
+			a:    "---\ntitle: [\n---\nbody\n",
+			b:    "---\ntitle: [\n---\nbody \n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := notesEquivalent([]byte(tt.a), []byte(tt.b), DefaultFrontmatterIgnore, tt.level)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDefaultFileComparer_Frontmatter(t *testing.T) {
+	tempDir := t.TempDir()
+	original := filepath.Join(tempDir, "note.md")
+	conflict := filepath.Join(tempDir, "note.sync-conflict-20240818-215425-I2NUVZU.md")
+	if err := os.WriteFile(original, []byte("---\ntitle: Note\nupdated: 2024-08-19\n---\nbody\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(conflict, []byte("---\nupdated: 2024-08-18\ntitle: Note\n---\nbody\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pair := ConflictPair{ConflictPath: conflict, OriginalPath: original}
+
+	deleted, err := (&DefaultFileComparer{}).CompareAndDelete(pair)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if deleted {
+		t.Fatal("Expected the conflict to be kept without frontmatter mode")
+	}
+
+	comparer := &DefaultFileComparer{
+		Ops:         &FileOps{DryRun: true, Out: io.Discard},
+		Frontmatter: true,
+		IgnoreKeys:  DefaultFrontmatterIgnore,
+	}
+	deleted, err = comparer.CompareAndDelete(pair)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !deleted {
+		t.Error("Expected the conflict to count as identical in frontmatter mode")
+	}
+}
+
+func TestDefaultDiffRunner_Frontmatter(t *testing.T) {
+	tempDir := t.TempDir()
+	original := filepath.Join(tempDir, "note.md")
+	conflict := filepath.Join(tempDir, "note.sync-conflict-20240818-215425-I2NUVZU.md")
+	if err := os.WriteFile(original, []byte("---\ntitle: New\ntags: [a]\nupdated: 2\n---\nbody\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(conflict, []byte("---\nupdated: 1\ntitle: Old\nalias: x\n---\nbody\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	differ := &DefaultDiffRunner{
+		Out:         &buf,
+		Frontmatter: true,
+		IgnoreKeys:  DefaultFrontmatterIgnore,
+	}
+	if err := differ.RunDiff(ConflictPair{ConflictPath: conflict, OriginalPath: original}, 1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	output := buf.String()
+	for _, expected := range []string{
+		"# frontmatter:\n",
+		"  - alias: \"x\"\n",
+		"  + tags: [\"a\"]\n",
+		"  ~ title: \"Old\" -> \"New\"\n",
+		"  ~ updated: 1 -> 2  (ignored)\n",
+		"Bodies match apart from whitespace\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, output)
+		}
+	}
+	if strings.Contains(output, "@@") {
+		t.Errorf("Expected no line diff, got:\n%s", output)
+	}
+}
+
+func TestJSONEquivalent(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want bool
+	}{
+		{name: "key order", a: `{"a":1,"b":[1,2]}`, b: "{\n  \"b\": [\n    1,\n    2\n  ],\n  \"a\": 1\n}\n", want: true},
+		{name: "number form", a: `{"x":1.0}`, b: `{"x":1}`, want: true},
+		{name: "array order", a: `[1,2]`, b: `[2,1]`},
+		{name: "value", a: `{"a":true}`, b: `{"a":false}`},
+		{name: "invalid", a: `{"a":`, b: `{"a":`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jsonEquivalent([]byte(tt.a), []byte(tt.b)); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDiffJSON(t *testing.T) {
+	conflict, err := decodeJSON([]byte(`{"nodes":[{"id":"a","x":1},{"id":"b"}],"theme":"dark","my key":[1,2]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	original, err := decodeJSON([]byte(`{"my key":[1],"nodes":[{"id":"c"},{"id":"a","x":2}],"theme":"dark","cssTheme":""}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, change := range diffJSON("$", conflict, original, true) {
+		got = append(got, change.String())
+	}
+	want := []string{
+		`+ $.cssTheme: ""`,
+		`+ $.nodes[id=c]: {"id":"c"}`,
+		`~ $.nodes[id=a].x: 1 -> 2`,
+		`- $.nodes[id=b]: {"id":"b"}`,
+		`- $["my key"][1]: 2`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestJSONMerger(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		base     string
+		original string
+		conflict string
+		want     string
+		clean    bool
+	}{
+		{
+			name:     "non-overlapping keys",
+			file:     "app.json",
+			original: "{\n  \"theme\": \"dark\",\n  \"fontSize\": 16\n}",
+			conflict: `{"fontSize":16,"spellcheck":true}`,
+			want:     "{\n  \"theme\": \"dark\",\n  \"fontSize\": 16,\n  \"spellcheck\": true\n}",
+			clean:    true,
+		},
+		{
+			name:     "same key changed",
+			file:     "app.json",
+			original: `{"theme":"dark"}`,
+			conflict: `{"theme":"light"}`,
+		},
+		{
+			name:     "base decides",
+			file:     "app.json",
+			base:     `{"theme":"dark","old":1}`,
+			original: `{"theme":"light","old":1}`,
+			conflict: `{"theme":"dark"}`,
+			want:     `{"theme":"light"}`,
+			clean:    true,
+		},
+		{
+			name:     "canvas nodes by id",
+			file:     "board.canvas",
+			original: "{\n\t\"nodes\":[\n\t\t{\"id\":\"a\",\"x\":0},\n\t\t{\"id\":\"b\",\"x\":0}\n\t],\n\t\"edges\":[]\n}\n",
+			conflict: `{"nodes":[{"id":"a","x":0},{"id":"c","x":5}],"edges":[{"id":"e","fromNode":"a","toNode":"c"}]}`,
+			want:     "{\n\t\"nodes\": [\n\t\t{\n\t\t\t\"id\": \"a\",\n\t\t\t\"x\": 0\n\t\t},\n\t\t{\n\t\t\t\"id\": \"b\",\n\t\t\t\"x\": 0\n\t\t},\n\t\t{\n\t\t\t\"id\": \"c\",\n\t\t\t\"x\": 5\n\t\t}\n\t],\n\t\"edges\": [\n\t\t{\n\t\t\t\"id\": \"e\",\n\t\t\t\"fromNode\": \"a\",\n\t\t\t\"toNode\": \"c\"\n\t\t}\n\t]\n}\n",
+			clean:    true,
+		},
+		{
+			name:     "canvas node moved on both sides",
+			file:     "board.canvas",
+			original: `{"nodes":[{"id":"a","x":1}]}`,
+			conflict: `{"nodes":[{"id":"a","x":2}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			if err := os.Mkdir(filepath.Join(root, stversionsDir), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			ext := filepath.Ext(tt.file)
+			stem := strings.TrimSuffix(tt.file, ext)
+			originalFile := filepath.Join(root, tt.file)
+			conflictFile := filepath.Join(root, stem+".sync-conflict-20240818-215425-I2NUVZU"+ext)
+			for path, content := range map[string]string{originalFile: tt.original, conflictFile: tt.conflict} {
+				if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.base != "" {
+				basePath := filepath.Join(root, stversionsDir, stem+"~20240801-000000"+ext)
+				if err := os.WriteFile(basePath, []byte(tt.base), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			now := time.Now()
+			result, err := (&JSONMerger{}).Merge(ConflictPair{
+				ConflictPath:    conflictFile,
+				OriginalPath:    originalFile,
+				ConflictModTime: now,
+				OriginalModTime: now,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Clean != tt.clean {
+				t.Fatalf("Expected clean %v, got %v (%s)", tt.clean, result.Clean, result.Reason)
+			}
+			if tt.clean && string(result.Content) != tt.want {
+				t.Errorf("Expected:\n%s\ngot:\n%s", tt.want, result.Content)
+			}
+			if !tt.clean && !strings.Contains(result.Reason, "$.") {
+				t.Errorf("Expected the reason to name JSON paths, got %q", result.Reason)
+			}
+		})
+	}
+
+	result, err := (&JSONMerger{}).Merge(ConflictPair{OriginalPath: "note.md"})
+	if err != nil || result.Clean || result.Reason != "" {
+		t.Errorf("Expected Markdown to be passed over, got %+v, %v", result, err)
+	}
+}
+
+func TestAppendOnlyMerger(t *testing.T) {
+	older := time.Date(2024, 8, 18, 9, 0, 0, 0, time.Local)
+	newer := older.Add(time.Hour)
+
+	tests := []struct {
+		name         string
+		file         string
+		base         string
+		original     string
+		conflict     string
+		originalTime time.Time
+		unversioned  bool
+		want         string
+		reason       string
+		handled      bool
+	}{
+		{
+			name:     "conflict is a prefix",
+			file:     "daily/2024-08-18.md",
+			original: "# Log\n- a\n- b\n",
+			conflict: "# Log\n- a\n",
+			want:     "# Log\n- a\n- b\n",
+			handled:  true,
+		},
+		{
+			name:     "original is a prefix without final newline",
+			file:     "daily/2024-08-18.md",
+			original: "# Log\n- a",
+			conflict: "# Log\n- a\n- c\n",
+			want:     "# Log\n- a\n- c\n",
+			handled:  true,
+		},
+		{
+			name:         "both appended, conflict older",
+			file:         "daily/2024-08-18.md",
+			base:         "# Log\n- a\n",
+			original:     "# Log\n- a\n- b\n",
+			conflict:     "# Log\n- a\n- c\n",
+			originalTime: newer,
+			want:         "# Log\n- a\n- c\n- b\n",
+			handled:      true,
+		},
+		{
+			name:         "both appended, original older",
+			file:         "daily/2024-08-18.md",
+			base:         "# Log\n- a\n",
+			original:     "# Log\n- a\n- b\n",
+			conflict:     "# Log\n- a\n- c\n",
+			originalTime: older.Add(-time.Hour),
+			want:         "# Log\n- a\n- b\n- c\n",
+			handled:      true,
+		},
+		{
+			name:     "earlier line edited",
+			file:     "daily/2024-08-18.md",
+			original: "# Log\n- a\n- b\n- end\n",
+			conflict: "# Log\n- a\n- B\n- end\n",
+			reason:   "no older version",
+			handled:  true,
+		},
+		{
+			name:     "edited last line without base",
+			file:     "daily/2024-08-18.md",
+			original: "# Day\n- buy milk and eggs\n",
+			conflict: "# Day\n- buy milk and bread\n",
+			reason:   "no older version",
+			handled:  true,
+		},
+		{
+			name:     "edited last line with base",
+			file:     "daily/2024-08-18.md",
+			base:     "# Day\n- buy milk\n",
+			original: "# Day\n- buy milk and eggs\n",
+			conflict: "# Day\n- buy milk and bread\n",
+			reason:   "not both versions start with",
+			handled:  true,
+		},
+		{
+			name:     "base not kept by one side",
+			file:     "daily/2024-08-18.md",
+			base:     "# Log\n- a\n",
+			original: "# Log\n- a\n- b\n",
+			conflict: "# Log\n- x\n",
+			reason:   "not both versions start with",
+			handled:  true,
+		},
+		{
+			name:     "line deleted from base, conflict is longer",
+			file:     "daily/2024-08-18.md",
+			base:     "a\nb\nc\n",
+			original: "a\nb\n",
+			conflict: "a\nb\nc\n",
+			reason:   "not both versions start with",
+			handled:  true,
+		},
+		{
+			name:     "base kept by both sides",
+			file:     "daily/2024-08-18.md",
+			base:     "# Log\n",
+			original: "# Log\n- b\n",
+			conflict: "# Log\n- c\n",
+			want:     "# Log\n- c\n- b\n",
+			handled:  true,
+		},
+		{
+			name:     "stale base, same line added on both sides",
+			file:     "daily/2024-08-18.md",
+			base:     "# Log\n",
+			original: "# Log\n- a\n- b\n",
+			conflict: "# Log\n- a\n- c\n",
+			reason:   "older than where they split",
+			handled:  true,
+		},
+		{
+			name:     "stale base, added line edited on one side",
+			file:     "daily/2024-08-18.md",
+			base:     "# Log\n",
+			original: "# Log\n- milk and eggs\n- b\n",
+			conflict: "# Log\n- milk\n- c\n",
+			reason:   "one line edited",
+			handled:  true,
+		},
+		{
+			name:         "unversioned, both added list items",
+			file:         "daily/2024-08-18.md",
+			original:     "# Log\n- a\n- b\n",
+			conflict:     "# Log\n- a\n- c\n",
+			originalTime: newer,
+			unversioned:  true,
+			want:         "# Log\n- a\n- c\n- b\n",
+			handled:      true,
+		},
+		{
+			name:         "unversioned, both added after a blank line",
+			file:         "daily/2024-08-18.md",
+			original:     "# Log\n\nwent running\n",
+			conflict:     "# Log\n\nread a book\n",
+			originalTime: newer,
+			unversioned:  true,
+			want:         "# Log\n\nread a book\nwent running\n",
+			handled:      true,
+		},
+		{
+			name:        "unversioned, not at a boundary",
+			file:        "daily/2024-08-18.md",
+			original:    "# Log\nwent running\n",
+			conflict:    "# Log\nread a book\n",
+			unversioned: true,
+			reason:      "not after a blank line or at a list item",
+			handled:     true,
+		},
+		{
+			name:        "unversioned, list item extended on one side",
+			file:        "daily/2024-08-18.md",
+			original:    "# Day\n- buy milk and eggs\n",
+			conflict:    "# Day\n- buy milk\n- call home\n",
+			unversioned: true,
+			reason:      "one line edited",
+			handled:     true,
+		},
+		{
+			name:        "unversioned, same line added on both sides",
+			file:        "daily/2024-08-18.md",
+			original:    "# Log\n- a\n- b\n",
+			conflict:    "# Log\n- c\n- b\n",
+			unversioned: true,
+			reason:      "both versions added",
+			handled:     true,
+		},
+		{
+			name:     "not matching a glob",
+			file:     "notes/idea.md",
+			original: "a\nb\n",
+			conflict: "a\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for _, dir := range []string{".obsidian", stversionsDir, filepath.Dir(tt.file)} {
+				if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ext := filepath.Ext(tt.file)
+			stem := strings.TrimSuffix(tt.file, ext)
+			originalFile := filepath.Join(root, tt.file)
+			conflictFile := filepath.Join(root, stem+".sync-conflict-20240818-215425-I2NUVZU"+ext)
+			for path, content := range map[string]string{originalFile: tt.original, conflictFile: tt.conflict} {
+				if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.base != "" {
+				basePath := filepath.Join(root, stversionsDir, stem+"~20240801-000000"+ext)
+				if err := os.MkdirAll(filepath.Dir(basePath), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(basePath, []byte(tt.base), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			originalTime := tt.originalTime
+			if originalTime.IsZero() {
+				originalTime = older
+			}
+
+			merger, err := NewAppendOnlyMerger([]string{"daily/**", "*.log"}, tt.unversioned)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := merger.Merge(ConflictPair{
+				ConflictPath:    conflictFile,
+				OriginalPath:    originalFile,
+				ConflictModTime: older,
+				OriginalModTime: originalTime,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if handled := result.Clean || result.Reason != ""; handled != tt.handled {
+				t.Fatalf("Expected handled %v, got %+v", tt.handled, result)
+			}
+			if tt.want != "" && (!result.Clean || string(result.Content) != tt.want) {
+				t.Errorf("Expected clean merge %q, got %+v", tt.want, result)
+			}
+			if tt.reason != "" && (result.Clean || !strings.Contains(result.Reason, tt.reason)) {
+				t.Errorf("Expected reason containing %q, got %+v", tt.reason, result)
+			}
+		})
+	}
+
+	if _, err := NewAppendOnlyMerger([]string{"[daily"}, false); err == nil {
+		t.Error("Expected an error for an invalid glob, but got none")
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name     string
+		conflict string
+		original string
+		level    string
+		want     string
+	}{
+		{name: "identical", conflict: "a\nb\n", original: "a\nb\n", want: ClassIdentical},
+		{name: "conflict is older snapshot", conflict: "a\nc\n", original: "a\nb\nc\nd", want: ClassConflictSubset},
+		{name: "original is older snapshot", conflict: "a\nb\nc\n", original: "a\nc", want: ClassOriginalSubset},
+		{name: "divergent", conflict: "a\nb\n", original: "a\nc\n", want: ClassDivergent},
+		{name: "reordered", conflict: "a\nb\n", original: "b\na\n", want: ClassDivergent},
+		{name: "subset at level", conflict: "a  \r\n", original: "a\nb\n", level: EquivalenceTrailingSpace, want: ClassConflictSubset},
+		{name: "not a subset when exact", conflict: "a  \n", original: "a\nb\n", want: ClassDivergent},
+		{name: "binary", conflict: "a\x00", original: "a\x00b", want: ClassDivergent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := classify([]byte(tt.conflict), []byte(tt.original), tt.level)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSyncConflictResolver_SubsetPolicy(t *testing.T) {
+	contents := map[string][2]string{
+		"older.md":    {"a\n", "a\nb\n"},
+		"newer.md":    {"a\nb\n", "a\n"},
+		"diverged.md": {"a\nb\n", "a\nc\n"},
+	}
+
+	for _, policy := range SubsetPolicies {
+		t.Run(policy, func(t *testing.T) {
+			vault := t.TempDir()
+			for name, content := range contents {
+				stem := strings.TrimSuffix(name, ".md")
+				conflict := filepath.Join(vault, stem+".sync-conflict-20240818-215425-I2NUVZU.md")
+				if err := os.WriteFile(conflict, []byte(content[0]), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(vault, name), []byte(content[1]), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var buf bytes.Buffer
+			reporter, err := NewReporter(OutputJSON, &buf)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			ops := &FileOps{Out: io.Discard}
+			resolver := &SyncConflictResolver{
+				finder:       &DefaultFileFinder{},
+				differ:       &mockDiffRunner{},
+				comparer:     &DefaultFileComparer{Ops: ops},
+				ops:          ops,
+				reporter:     reporter,
+				logger:       testr.New(t),
+				subsetPolicy: policy,
+			}
+			if err := resolver.ResolveSyncConflicts([]string{vault}, nil); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var reports []ConflictReport
+			if err := json.Unmarshal(buf.Bytes(), &reports); err != nil {
+				t.Fatalf("Expected a JSON array, got %q: %v", buf.String(), err)
+			}
+			got := map[string]string{}
+			for _, report := range reports {
+				got[filepath.Base(report.OriginalPath)] = report.Classification + " " + report.Action
+			}
+
+			want := map[string]string{
+				"older.md":    ClassConflictSubset + " " + ActionDiffering,
+				"newer.md":    ClassOriginalSubset + " " + ActionDiffering,
+				"diverged.md": ClassDivergent + " " + ActionDiffering,
+			}
+			if policy != SubsetReport {
+				want["older.md"] = ClassConflictSubset + " " + ActionDeletedSubset
+			}
+			if policy == SubsetKeepSuperset {
+				want["newer.md"] = ClassOriginalSubset + " " + ActionReplacedOriginal
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Expected %v, got %v", want, got)
+			}
+
+			content, err := os.ReadFile(filepath.Join(vault, "newer.md"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if replaced := string(content) == "a\nb\n"; replaced != (policy == SubsetKeepSuperset) {
+				t.Errorf("Unexpected content of newer.md: %q", content)
+			}
+		})
+	}
+
+	if _, err := NewSyncConflictResolver(testr.New(t), Options{SubsetPolicy: "everything"}); err == nil {
+		t.Error("Expected an error for an unknown subset policy, but got none")
+	}
+}
+
+func TestDefaultFileFinder_SkipsUnreadableConflict(t *testing.T) {
+	vault := t.TempDir()
+	for name, content := range map[string]string{
+		"a.md": "original",
+		"a.sync-conflict-20240818-215425-I2NUVZU.md": "conflict",
+	} {
+		if err := os.WriteFile(filepath.Join(vault, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dangling := filepath.Join(vault, "b.sync-conflict-20240818-215425-I2NUVZU.md")
+	if err := os.Symlink(filepath.Join(vault, "missing"), dangling); err != nil {
+		t.Skipf("Cannot create symlinks: %v", err)
+	}
+
+	pairs, err := (&DefaultFileFinder{Logger: testr.New(t)}).FindSyncConflictFiles([]string{vault}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(pairs) != 1 || filepath.Base(pairs[0].OriginalPath) != "a.md" {
+		t.Errorf("Expected only the conflict of a.md, got %+v", pairs)
+	}
+}
@@ -0,0 +1,304 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	DetectorSyncthing   = "syncthing"
+	DetectorDropbox     = "dropbox"
+	DetectorNextcloud   = "nextcloud"
+	DetectorOneDrive    = "onedrive"
+	DetectorGoogleDrive = "google-drive"
+)
+
+var DetectorNames = []string{
+	DetectorSyncthing,
+	DetectorDropbox,
+	DetectorNextcloud,
+	DetectorOneDrive,
+	DetectorGoogleDrive,
+}
+
+// Detection describes what a detector learned from a conflict file name.
+type Detection struct {
+	OriginalPath string
+	Timestamp    time.Time
+	DeviceID     string
+	Ext          string
+	// Generation counts how many conflict markers separate the file from
+	// its original; Parent is the conflict copy one generation down.
+	Generation int
+	Parent     string
+}
+
+type ConflictDetector interface {
+	Name() string
+	Detect(conflictFile string) (Detection, bool)
+}
+
+func NewDetectors(names, hostnames []string) ([]ConflictDetector, error) {
+	var detectors []ConflictDetector
+	for _, name := range names {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case DetectorSyncthing:
+			detectors = append(detectors, &SyncthingDetector{})
+		case DetectorDropbox:
+			detectors = append(detectors, &DropboxDetector{})
+		case DetectorNextcloud:
+			detectors = append(detectors, &NextcloudDetector{})
+		case DetectorOneDrive:
+			if len(hostnames) == 0 {
+				if host, err := os.Hostname(); err == nil {
+					hostnames = []string{host}
+				}
+			}
+			detectors = append(detectors, &OneDriveDetector{Hostnames: hostnames})
+		case DetectorGoogleDrive:
+			detectors = append(detectors, &GoogleDriveDetector{})
+		default:
+			return nil, fmt.Errorf(
+				"unknown conflict detector %q, expected one of %s",
+				name,
+				strings.Join(DetectorNames, ", "),
+			)
+		}
+	}
+	return detectors, nil
+}
+
+func defaultDetectors() []ConflictDetector {
+	return []ConflictDetector{&SyncthingDetector{}}
+}
+
+func detect(
+	conflictFile string,
+	detectors []ConflictDetector,
+) (Detection, string, bool) {
+	if len(detectors) == 0 {
+		detectors = defaultDetectors()
+	}
+	for _, detector := range detectors {
+		if detection, ok := detector.Detect(conflictFile); ok {
+			return detection, detector.Name(), true
+		}
+	}
+	return Detection{}, "", false
+}
+
+// Syncthing names conflict copies <base>.sync-conflict-<date>-<time>-<device><ext>
+// where <ext> is whatever filepath.Ext returned for the original, possibly
+// nothing at all.
+var syncConflictPattern = regexp.MustCompile(
+	`^(.*)\.sync-conflict-(\d{8})-(\d{6})-(\w+)(\.[^.]*)?$`,
+)
+
+type SyncthingDetector struct{}
+
+func (d *SyncthingDetector) Name() string {
+	return DetectorSyncthing
+}
+
+// Detect follows chains such as
+// note.sync-conflict-A.sync-conflict-B.md, which Syncthing produces when a
+// conflict copy itself conflicts, all the way back to note.md.
+func (d *SyncthingDetector) Detect(conflictFile string) (Detection, bool) {
+	dir, name := filepath.Split(conflictFile)
+	m := syncConflictPattern.FindStringSubmatch(name)
+	if m == nil {
+		return Detection{}, false
+	}
+
+	timestamp, err := time.ParseInLocation(
+		"20060102-150405",
+		m[2]+"-"+m[3],
+		time.Local,
+	)
+	if err != nil {
+		return Detection{}, false
+	}
+
+	detection := Detection{
+		Timestamp:  timestamp,
+		DeviceID:   m[4],
+		Ext:        m[5],
+		Generation: 1,
+	}
+
+	original := m[1] + m[5]
+	if syncConflictPattern.MatchString(original) {
+		detection.Parent = dir + original
+	}
+	for {
+		m := syncConflictPattern.FindStringSubmatch(original)
+		if m == nil {
+			break
+		}
+		original = m[1] + m[5]
+		detection.Generation++
+	}
+	detection.OriginalPath = dir + original
+
+	return detection, true
+}
+
+func originalPath(conflictFile string) (string, bool) {
+	detection, ok := (&SyncthingDetector{}).Detect(conflictFile)
+	if !ok {
+		return "", false
+	}
+	return detection.OriginalPath, true
+}
+
+// Dropbox: "name (Bob's conflicted copy 2024-08-18).md", optionally with a
+// counter after the date when several copies are made on the same day.
+var dropboxPattern = regexp.MustCompile(
+	`^(.*) \((.+)'s conflicted copy (\d{4}-\d{2}-\d{2})(?: \(\d+\))?\)(\.[^.]*)?$`,
+)
+
+type DropboxDetector struct{}
+
+func (d *DropboxDetector) Name() string {
+	return DetectorDropbox
+}
+
+func (d *DropboxDetector) Detect(conflictFile string) (Detection, bool) {
+	dir, name := filepath.Split(conflictFile)
+	m := dropboxPattern.FindStringSubmatch(name)
+	if m == nil {
+		return Detection{}, false
+	}
+
+	timestamp, err := time.ParseInLocation("2006-01-02", m[3], time.Local)
+	if err != nil {
+		return Detection{}, false
+	}
+
+	return Detection{
+		OriginalPath: dir + m[1] + m[4],
+		Timestamp:    timestamp,
+		DeviceID:     m[2],
+		Ext:          m[4],
+		Generation:   1,
+	}, true
+}
+
+// Nextcloud: "name (conflicted copy 2024-08-18 215425).md".
+var nextcloudPattern = regexp.MustCompile(
+	`^(.*) \(conflicted copy (\d{4}-\d{2}-\d{2}) (\d{6})\)(\.[^.]*)?$`,
+)
+
+type NextcloudDetector struct{}
+
+func (d *NextcloudDetector) Name() string {
+	return DetectorNextcloud
+}
+
+func (d *NextcloudDetector) Detect(conflictFile string) (Detection, bool) {
+	dir, name := filepath.Split(conflictFile)
+	m := nextcloudPattern.FindStringSubmatch(name)
+	if m == nil {
+		return Detection{}, false
+	}
+
+	timestamp, err := time.ParseInLocation(
+		"2006-01-02 150405",
+		m[2]+" "+m[3],
+		time.Local,
+	)
+	if err != nil {
+		return Detection{}, false
+	}
+
+	return Detection{
+		OriginalPath: dir + m[1] + m[4],
+		Timestamp:    timestamp,
+		Ext:          m[4],
+		Generation:   1,
+	}, true
+}
+
+// OneDrive appends the name of the computer that lost the race:
+// "name-HOSTNAME.md". Such names are common for ordinary notes too, so a
+// match also needs one of the configured hostnames and an existing original.
+type OneDriveDetector struct {
+	Hostnames []string
+}
+
+func (d *OneDriveDetector) Name() string {
+	return DetectorOneDrive
+}
+
+func (d *OneDriveDetector) Detect(conflictFile string) (Detection, bool) {
+	dir, name := filepath.Split(conflictFile)
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+
+	stems := []string{stem}
+	if m := counterSuffixPattern.FindStringSubmatch(stem); m != nil {
+		stems = append(stems, m[1])
+	}
+
+	for _, host := range d.Hostnames {
+		suffix := "-" + strings.ToLower(host)
+		if host == "" {
+			continue
+		}
+		for _, candidate := range stems {
+			if len(candidate) <= len(suffix) ||
+				!strings.HasSuffix(strings.ToLower(candidate), suffix) {
+				continue
+			}
+			original := dir + candidate[:len(candidate)-len(suffix)] + ext
+			if !fileExists(original) {
+				continue
+			}
+			return Detection{
+				OriginalPath: original,
+				DeviceID:     host,
+				Ext:          ext,
+				Generation:   1,
+			}, true
+		}
+	}
+	return Detection{}, false
+}
+
+var counterSuffixPattern = regexp.MustCompile(`^(.*)-\d+$`)
+
+// Google Drive for desktop: "name (1).md". Only counts when the original
+// exists, since plenty of notes are legitimately named like that.
+var googleDrivePattern = regexp.MustCompile(`^(.*) \((\d+)\)(\.[^.]*)?$`)
+
+type GoogleDriveDetector struct{}
+
+func (d *GoogleDriveDetector) Name() string {
+	return DetectorGoogleDrive
+}
+
+func (d *GoogleDriveDetector) Detect(conflictFile string) (Detection, bool) {
+	dir, name := filepath.Split(conflictFile)
+	m := googleDrivePattern.FindStringSubmatch(name)
+	if m == nil {
+		return Detection{}, false
+	}
+	original := dir + m[1] + m[3]
+	if !fileExists(original) {
+		return Detection{}, false
+	}
+	return Detection{
+		OriginalPath: original,
+		Ext:          m[3],
+		Generation:   1,
+	}, true
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
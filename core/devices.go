@@ -0,0 +1,47 @@
+package core
+
+import "strings"
+
+// shortIDLen is how much of a device ID Syncthing puts in conflict names.
+const shortIDLen = 7
+
+// DeviceNames maps the device part of a conflict name to a friendly name.
+// Keys are upper case, since config keys may have been folded to lower
+// case on the way in.
+type DeviceNames map[string]string
+
+// NewDeviceNames combines the devices in a Syncthing config with names set
+// by hand, which win. Either may be nil.
+func NewDeviceNames(
+	config *SyncthingConfig,
+	names map[string]string,
+) DeviceNames {
+	devices := DeviceNames{}
+	if config != nil {
+		for _, device := range config.Devices {
+			if device.Name == "" {
+				continue
+			}
+			devices[shortDeviceID(device.ID)] = device.Name
+		}
+	}
+	for id, name := range names {
+		devices[strings.ToUpper(id)] = name
+	}
+	return devices
+}
+
+func (d DeviceNames) Name(id string) string {
+	if id == "" {
+		return ""
+	}
+	return d[strings.ToUpper(id)]
+}
+
+func shortDeviceID(id string) string {
+	short := strings.ToUpper(strings.ReplaceAll(id, "-", ""))
+	if len(short) > shortIDLen {
+		short = short[:shortIDLen]
+	}
+	return short
+}
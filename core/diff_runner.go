@@ -1,36 +1,189 @@
 package core
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"os"
 	"path/filepath"
-	"strings"
+
+	"github.com/gkwa/lessmay/internal/diff"
 )
 
-type DefaultDiffRunner struct{}
+type DefaultDiffRunner struct {
+	Out            io.Writer
+	IgnoreAllSpace bool
+	URIs           *URIBuilder
+	Opener         *Opener
+	// Frontmatter lists frontmatter changes in Markdown notes key by key
+	// and diffs only the bodies.
+	Frontmatter bool
+	IgnoreKeys  []string
+	// SemanticJSON lists the JSON paths at which .json and .canvas files
+	// differ instead of diffing their lines.
+	SemanticJSON bool
+}
 
-func (d *DefaultDiffRunner) RunDiff(
-	conflictFile, originalFile string,
-	count int,
-) error {
-	diffCmd := []string{
-		"diff",
-		"--unified",
-		"--ignore-all-space",
-		strings.ReplaceAll(conflictFile, "'", "'\"'\"'"),
-		strings.ReplaceAll(originalFile, "'", "'\"'\"'"),
+func (d *DefaultDiffRunner) RunDiff(pair ConflictPair, count int) error {
+	out := d.Out
+	if out == nil {
+		out = os.Stdout
+	}
+
+	absConflictFile, _ := filepath.Abs(pair.ConflictPath)
+	absOriginalFile, _ := filepath.Abs(pair.OriginalPath)
+
+	fmt.Fprintf(out, "# diff: %d\n", count)
+	fmt.Fprintf(out, "%s\n", absConflictFile)
+	fmt.Fprintf(out, "%s\n", absOriginalFile)
+	if pair.Classification != "" {
+		fmt.Fprintf(out, "# classification: %s\n", pair.Classification)
 	}
 
-	formattedCmd := strings.Join(diffCmd, " ")
+	conflictContent, err := os.ReadFile(pair.ConflictPath)
+	if err != nil {
+		return fmt.Errorf("error reading conflict file: %w", err)
+	}
 
-	absConflictFile, _ := filepath.Abs(conflictFile)
-	absOriginalFile, _ := filepath.Abs(originalFile)
+	originalContent, err := os.ReadFile(pair.OriginalPath)
+	if err != nil {
+		return fmt.Errorf("error reading original file: %w", err)
+	}
 
-	fmt.Printf("# diff: %d\n", count)
-	fmt.Printf("%s\n", formattedCmd)
-	fmt.Printf("%s\n", absConflictFile)
-	fmt.Printf("%s\n", absOriginalFile)
-	fmt.Printf("open obsidian:'//open?path=%s'; ", absOriginalFile)
-	fmt.Printf("open obsidian:'//open?path=%s'\n", absConflictFile)
+	switch {
+	case isBinary(conflictContent) || isBinary(originalContent):
+		fmt.Fprintf(
+			out,
+			"Binary files %s and %s differ\n",
+			pair.ConflictPath,
+			pair.OriginalPath,
+		)
+	case d.SemanticJSON && isJSONDocument(pair.OriginalPath) &&
+		d.writeJSON(out, pair, conflictContent, originalContent):
+		// The JSON paths stand in for the line diff.
+	default:
+		if err := d.writeLines(out, pair, conflictContent, originalContent); err != nil {
+			return err
+		}
+	}
 
+	opener := d.Opener
+	if opener == nil {
+		opener = NewOpener("")
+	}
+	fmt.Fprintf(
+		out,
+		"%s; %s\n",
+		opener.CommandLine(d.URIs.URI(absOriginalFile)),
+		opener.CommandLine(d.URIs.URI(absConflictFile)),
+	)
+
+	return nil
+}
+
+func (d *DefaultDiffRunner) writeLines(
+	out io.Writer,
+	pair ConflictPair,
+	conflictContent, originalContent []byte,
+) error {
+	// Like GNU diff's timestamps, the device goes after a tab so that
+	// patch still finds the file name.
+	conflictName := pair.ConflictPath
+	if label := pair.DeviceLabel(); label != "" {
+		conflictName += "\t" + label
+	}
+	notDiffed := "Files differ only in whitespace"
+	if d.Frontmatter && isMarkdown(pair.OriginalPath) {
+		if conflictNote, originalNote, ok := d.writeFrontmatter(
+			out,
+			conflictContent,
+			originalContent,
+		); ok {
+			conflictContent, originalContent = conflictNote.Body, originalNote.Body
+			notDiffed = "Bodies match apart from whitespace"
+		}
+	}
+	stats, err := diff.WriteUnified(
+		out,
+		conflictName,
+		pair.OriginalPath,
+		diff.SplitLines(string(conflictContent)),
+		diff.SplitLines(string(originalContent)),
+		diff.Options{IgnoreAllSpace: d.IgnoreAllSpace},
+	)
+	if err != nil {
+		return fmt.Errorf("error writing diff: %w", err)
+	}
+	if !stats.Changed() {
+		fmt.Fprintln(out, notDiffed)
+	}
 	return nil
 }
+
+// writeJSON lists the JSON paths at which the documents differ. It reports
+// false, leaving the files to be diffed line by line, when either does not
+// parse.
+func (d *DefaultDiffRunner) writeJSON(
+	out io.Writer,
+	pair ConflictPair,
+	conflictContent, originalContent []byte,
+) bool {
+	conflict, err := decodeJSON(conflictContent)
+	if err != nil {
+		return false
+	}
+	original, err := decodeJSON(originalContent)
+	if err != nil {
+		return false
+	}
+
+	changes := diffJSON("$", conflict, original, mergesByID(pair.OriginalPath))
+	fmt.Fprintln(out, "# json:")
+	if len(changes) == 0 {
+		fmt.Fprintln(out, "  (same)")
+	}
+	for _, change := range changes {
+		fmt.Fprintf(out, "  %s\n", change)
+	}
+	return true
+}
+
+// writeFrontmatter lists the frontmatter keys that differ. It reports false,
+// leaving the whole files to be diffed, when neither note has frontmatter
+// or either does not parse.
+func (d *DefaultDiffRunner) writeFrontmatter(
+	out io.Writer,
+	conflictContent, originalContent []byte,
+) (*note, *note, bool) {
+	_, _, conflictHas := splitFrontmatter(conflictContent)
+	_, _, originalHas := splitFrontmatter(originalContent)
+	if !conflictHas && !originalHas {
+		return nil, nil, false
+	}
+	conflictNote, err := parseNote(conflictContent)
+	if err != nil {
+		return nil, nil, false
+	}
+	originalNote, err := parseNote(originalContent)
+	if err != nil {
+		return nil, nil, false
+	}
+
+	changes := diffFrontmatter(conflictNote.Fields, originalNote.Fields, d.IgnoreKeys)
+	fmt.Fprintln(out, "# frontmatter:")
+	if len(changes) == 0 {
+		fmt.Fprintln(out, "  (same)")
+	}
+	for _, change := range changes {
+		fmt.Fprintf(out, "  %s\n", change)
+	}
+	return conflictNote, originalNote, true
+}
+
+func isBinary(content []byte) bool {
+	const sniffLen = 8000
+	if len(content) > sniffLen {
+		content = content[:sniffLen]
+	}
+	return bytes.IndexByte(content, 0) >= 0
+}
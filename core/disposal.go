@@ -0,0 +1,317 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+const (
+	DisposeDelete     = "delete"
+	DisposeVaultTrash = "vault-trash"
+	DisposeOSTrash    = "os-trash"
+	DisposeQuarantine = "quarantine"
+)
+
+var DisposalStrategies = []string{
+	DisposeDelete,
+	DisposeVaultTrash,
+	DisposeOSTrash,
+	DisposeQuarantine,
+}
+
+type Disposer interface {
+	Dispose(path string) (string, error)
+}
+
+func NewDisposer(strategy, quarantineDir string) (Disposer, error) {
+	switch strategy {
+	case "", DisposeDelete:
+		return &deleteDisposer{}, nil
+	case DisposeVaultTrash:
+		return &vaultTrashDisposer{}, nil
+	case DisposeOSTrash:
+		return &osTrashDisposer{now: time.Now}, nil
+	case DisposeQuarantine:
+		if quarantineDir == "" {
+			dir, err := stateDir()
+			if err != nil {
+				return nil, err
+			}
+			quarantineDir = filepath.Join(dir, "quarantine")
+		}
+		expanded, err := homedir.Expand(quarantineDir)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"failed to expand path %s: %w",
+				quarantineDir,
+				err,
+			)
+		}
+		return &quarantineDisposer{
+			dir: filepath.Join(
+				expanded,
+				time.Now().Format("20060102-150405"),
+			),
+		}, nil
+	default:
+		return nil, fmt.Errorf(
+			"unknown disposal strategy %q, expected one of %s",
+			strategy,
+			strings.Join(DisposalStrategies, ", "),
+		)
+	}
+}
+
+type deleteDisposer struct{}
+
+func (d *deleteDisposer) Dispose(path string) (string, error) {
+	return "", os.Remove(path)
+}
+
+// vaultTrashDisposer mirrors Obsidian's "Move to Obsidian trash" setting:
+// files end up flat in <vault>/.trash.
+type vaultTrashDisposer struct{}
+
+func (d *vaultTrashDisposer) Dispose(path string) (string, error) {
+	root := findVaultRoot(filepath.Dir(path))
+	if root == "" {
+		return "", fmt.Errorf("%s is not inside an Obsidian vault", path)
+	}
+
+	trashDir := filepath.Join(root, ".trash")
+	if err := os.MkdirAll(trashDir, 0o755); err != nil {
+		return "", err
+	}
+
+	target, err := uniquePath(trashDir, filepath.Base(path))
+	if err != nil {
+		return "", err
+	}
+	return target, moveFile(path, target)
+}
+
+// findVaultRoot returns the closest directory holding an .obsidian folder,
+// falling back to the Syncthing folder root.
+func findVaultRoot(dir string) string {
+	for _, marker := range []string{".obsidian", ".stfolder"} {
+		if root := findAncestorWith(dir, marker); root != "" {
+			return root
+		}
+	}
+	return ""
+}
+
+func findAncestorWith(dir, name string) string {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// osTrashDisposer follows the freedesktop.org trash specification on Linux
+// and uses ~/.Trash on macOS.
+type osTrashDisposer struct {
+	now func() time.Time
+}
+
+func (d *osTrashDisposer) Dispose(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		home, err := homedir.Dir()
+		if err != nil {
+			return "", err
+		}
+		target, err := uniquePath(
+			filepath.Join(home, ".Trash"),
+			filepath.Base(abs),
+		)
+		if err != nil {
+			return "", err
+		}
+		return target, moveFile(abs, target)
+	case "windows":
+		return "", fmt.Errorf("the OS trash is not supported on Windows")
+	}
+
+	trashDir, err := xdgTrashDir()
+	if err != nil {
+		return "", err
+	}
+	filesDir := filepath.Join(trashDir, "files")
+	infoDir := filepath.Join(trashDir, "info")
+	for _, dir := range []string{filesDir, infoDir} {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return "", err
+		}
+	}
+
+	target, err := uniquePath(filesDir, filepath.Base(abs))
+	if err != nil {
+		return "", err
+	}
+
+	infoPath := filepath.Join(infoDir, filepath.Base(target)+".trashinfo")
+	info := fmt.Sprintf(
+		"[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: abs}).EscapedPath(),
+		d.now().Format("2006-01-02T15:04:05"),
+	)
+	if err := os.WriteFile(infoPath, []byte(info), 0o600); err != nil {
+		return "", err
+	}
+
+	if err := moveFile(abs, target); err != nil {
+		os.Remove(infoPath)
+		return "", err
+	}
+	return target, nil
+}
+
+func xdgTrashDir() (string, error) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return "", err
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dataHome, "Trash"), nil
+}
+
+// quarantineDisposer keeps the absolute layout of each file under a
+// directory named after the time the run started.
+type quarantineDisposer struct {
+	dir string
+}
+
+func (d *quarantineDisposer) Dispose(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	rel := strings.TrimPrefix(abs, filepath.VolumeName(abs))
+	target := filepath.Join(d.dir, rel)
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return "", err
+	}
+	target, err = uniquePath(filepath.Dir(target), filepath.Base(target))
+	if err != nil {
+		return "", err
+	}
+	return target, moveFile(abs, target)
+}
+
+func stateDir() (string, error) {
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if stateHome == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return "", err
+		}
+		stateHome = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(stateHome, "lessmay"), nil
+}
+
+func uniquePath(dir, name string) (string, error) {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	candidate := filepath.Join(dir, name)
+	for i := 1; ; i++ {
+		_, err := os.Lstat(candidate)
+		if errors.Is(err, fs.ErrNotExist) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		candidate = filepath.Join(dir, fmt.Sprintf("%s %d%s", base, i, ext))
+	}
+}
+
+// moveFile renames from to to, copying when the two are on different
+// filesystems. If the copy cannot be followed by removing from, the copy
+// is deleted again so the file is never in both places.
+func moveFile(from, to string) error {
+	err := os.Rename(from, to)
+	if err == nil || !crossDevice(err) {
+		return err
+	}
+
+	if copyErr := copyFile(from, to); copyErr != nil {
+		return fmt.Errorf("%w; copying instead: %w", err, copyErr)
+	}
+	if err := os.Remove(from); err != nil {
+		os.Remove(to)
+		return err
+	}
+	return nil
+}
+
+// errNotSameDevice is ERROR_NOT_SAME_DEVICE, which Windows returns instead
+// of EXDEV.
+const errNotSameDevice = syscall.Errno(17)
+
+func crossDevice(err error) bool {
+	if runtime.GOOS == "windows" {
+		return errors.Is(err, errNotSameDevice)
+	}
+	return errors.Is(err, syscall.EXDEV)
+}
+
+func copyFile(from, to string) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst, err := os.OpenFile(
+		to,
+		os.O_WRONLY|os.O_CREATE|os.O_EXCL,
+		info.Mode().Perm(),
+	)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(to)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(to)
+		return err
+	}
+	return os.Chtimes(to, info.ModTime(), info.ModTime())
+}
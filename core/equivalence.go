@@ -0,0 +1,97 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Equivalence levels decide when a conflict copy counts as identical to
+// its original. Each level also ignores everything the ones before it do.
+const (
+	// EquivalenceExact compares bytes.
+	EquivalenceExact = "exact"
+	// EquivalenceNewlines ignores CRLF vs LF, a UTF-8 byte order mark and
+	// a missing or extra newline at the end of the file.
+	EquivalenceNewlines = "newlines"
+	// EquivalenceTrailingSpace ignores whitespace at the end of lines.
+	EquivalenceTrailingSpace = "trailing-whitespace"
+	// EquivalenceUnicode ignores NFD vs NFC, as written by macOS and Linux
+	// peers respectively.
+	EquivalenceUnicode = "unicode"
+	// EquivalenceWhitespace ignores all whitespace.
+	EquivalenceWhitespace = "whitespace"
+)
+
+var EquivalenceLevels = []string{
+	EquivalenceExact,
+	EquivalenceNewlines,
+	EquivalenceTrailingSpace,
+	EquivalenceUnicode,
+	EquivalenceWhitespace,
+}
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+func equivalenceRank(level string) (int, error) {
+	if level == "" {
+		return 0, nil
+	}
+	for i, l := range EquivalenceLevels {
+		if l == level {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf(
+		"unknown equivalence level %q, expected one of %s",
+		level,
+		strings.Join(EquivalenceLevels, ", "),
+	)
+}
+
+// equivalent compares a and b at the given level. Binary content is only
+// ever compared byte for byte.
+func equivalent(a, b []byte, level string) (bool, error) {
+	rank, err := equivalenceRank(level)
+	if err != nil {
+		return false, err
+	}
+	if bytes.Equal(a, b) {
+		return true, nil
+	}
+	if rank == 0 || isBinary(a) || isBinary(b) {
+		return false, nil
+	}
+	return bytes.Equal(normalize(a, rank), normalize(b, rank)), nil
+}
+
+func normalize(content []byte, rank int) []byte {
+	content = bytes.TrimPrefix(content, utf8BOM)
+	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	content = bytes.ReplaceAll(content, []byte("\r"), []byte("\n"))
+	// Only one final newline; an extra blank line is still a change.
+	content = bytes.TrimSuffix(content, []byte("\n"))
+
+	if rank >= 2 {
+		lines := bytes.Split(content, []byte("\n"))
+		for i, line := range lines {
+			lines[i] = bytes.TrimRightFunc(line, unicode.IsSpace)
+		}
+		content = bytes.Join(lines, []byte("\n"))
+	}
+	if rank >= 3 {
+		content = norm.NFC.Bytes(content)
+	}
+	if rank >= 4 {
+		content = bytes.Map(func(r rune) rune {
+			if unicode.IsSpace(r) {
+				return -1
+			}
+			return r
+		}, content)
+	}
+	return content
+}
@@ -1,28 +1,56 @@
 package core
 
 import (
-	"bytes"
 	"fmt"
 	"os"
 )
 
-type DefaultFileComparer struct{}
+type DefaultFileComparer struct {
+	Ops *FileOps
+	// Equivalence is one of EquivalenceLevels; empty means exact.
+	Equivalence string
+	// Frontmatter compares Markdown notes by their parsed frontmatter,
+	// leaving out IgnoreKeys, and their body separately.
+	Frontmatter bool
+	IgnoreKeys  []string
+	// SemanticJSON treats .json and .canvas files holding the same data,
+	// in any key order or formatting, as identical.
+	SemanticJSON bool
+}
 
 func (c *DefaultFileComparer) CompareAndDelete(
-	conflictFile, originalFile string,
+	pair ConflictPair,
 ) (bool, error) {
-	conflictContent, err := os.ReadFile(conflictFile)
+	conflictContent, err := os.ReadFile(pair.ConflictPath)
 	if err != nil {
 		return false, fmt.Errorf("error reading conflict file: %w", err)
 	}
 
-	originalContent, err := os.ReadFile(originalFile)
+	originalContent, err := os.ReadFile(pair.OriginalPath)
 	if err != nil {
 		return false, fmt.Errorf("error reading original file: %w", err)
 	}
 
-	if bytes.Equal(conflictContent, originalContent) {
-		err := os.Remove(conflictFile)
+	same, err := equivalent(conflictContent, originalContent, c.Equivalence)
+	if err != nil {
+		return false, err
+	}
+	if !same && c.SemanticJSON && isJSONDocument(pair.OriginalPath) {
+		same = jsonEquivalent(conflictContent, originalContent)
+	}
+	if !same && c.Frontmatter && isMarkdown(pair.OriginalPath) {
+		same, err = notesEquivalent(
+			conflictContent,
+			originalContent,
+			c.IgnoreKeys,
+			c.Equivalence,
+		)
+		if err != nil {
+			return false, err
+		}
+	}
+	if same {
+		err := opsOrDefault(c.Ops).Remove(pair.ConflictPath)
 		if err != nil {
 			return false, fmt.Errorf("error deleting conflict file: %w", err)
 		}
@@ -6,27 +6,54 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+
+	"github.com/go-logr/logr"
 )
 
-type DefaultFileFinder struct{}
+type DefaultFileFinder struct {
+	Detectors []ConflictDetector
+	// VaultDetectors overrides Detectors for everything below a vault root.
+	VaultDetectors map[string][]ConflictDetector
+	// NoStignore walks paths that the folder's .stignore excludes.
+	NoStignore bool
+	// SkipRegexes leave out paths whose slash-separated path relative to
+	// the scan root matches.
+	SkipRegexes []*regexp.Regexp
+	// Include, when set, limits the search to files matching one of these
+	// globs.
+	Include []string
+	// DeviceNames fills in ConflictPair.DeviceName.
+	DeviceNames DeviceNames
+	// Logger hears about conflict files that cannot be read, which are
+	// skipped so that one of them does not stop the search.
+	Logger logr.Logger
+}
 
 func (f *DefaultFileFinder) FindSyncConflictFiles(
 	paths, skipPaths []string,
-) ([]string, error) {
-	var conflictFiles []string
-	pattern := regexp.MustCompile(`\.sync-conflict-\d{8}-\d{6}-\w+\.md$`)
+) ([]ConflictPair, error) {
+	var pairs []ConflictPair
 
 	for _, path := range paths {
-		err := filepath.WalkDir(
+		err := f.walk(
 			path,
-			func(path string, d fs.DirEntry, err error) error {
+			skipPaths,
+			func(path string, d fs.DirEntry) error {
+				if d.IsDir() {
+					return nil
+				}
+				pair, ok, err := f.conflictPair(path)
 				if err != nil {
-					return err
+					f.Logger.Error(
+						err,
+						"Skipping unreadable sync conflict file",
+						"conflictFile",
+						path,
+					)
+					return nil
 				}
-				if !d.IsDir() && pattern.MatchString(d.Name()) {
-					if !shouldSkip(path, skipPaths) {
-						conflictFiles = append(conflictFiles, path)
-					}
+				if ok {
+					pairs = append(pairs, pair)
 				}
 				return nil
 			},
@@ -36,14 +63,108 @@ func (f *DefaultFileFinder) FindSyncConflictFiles(
 		}
 	}
 
-	return conflictFiles, nil
+	return pairs, nil
+}
+
+// walk calls fn for root and everything below it that is not skipped,
+// ignored by the Syncthing folder's .stignore, or left out by Include.
+func (f *DefaultFileFinder) walk(
+	root string,
+	skipPaths []string,
+	fn func(path string, d fs.DirEntry) error,
+) error {
+	filter, err := f.filter(root, skipPaths)
+	if err != nil {
+		return err
+	}
+
+	return filepath.WalkDir(
+		root,
+		func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			skip, skipDir := filter.check(path, d.IsDir())
+			if skipDir {
+				return filepath.SkipDir
+			}
+			if skip && !d.IsDir() {
+				return nil
+			}
+			return fn(path, d)
+		},
+	)
+}
+
+func (f *DefaultFileFinder) filter(
+	root string,
+	skipPaths []string,
+) (*pathFilter, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	skip, err := compileGlobs(skipPaths)
+	if err != nil {
+		return nil, err
+	}
+	include, err := compileGlobs(f.Include)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := &pathFilter{
+		root:        abs,
+		skip:        skip,
+		skipRegexes: f.SkipRegexes,
+		include:     include,
+	}
+	if !f.NoStignore {
+		filter.ignore, err = LoadStignore(root)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", StignoreFile, err)
+		}
+	}
+	return filter, nil
+}
+
+func (f *DefaultFileFinder) conflictPair(
+	path string,
+) (ConflictPair, bool, error) {
+	detection, detector, ok := detect(path, f.detectorsFor(path))
+	if !ok {
+		return ConflictPair{}, false, nil
+	}
+	pair, err := pairFromDetection(path, detection, detector)
+	if err != nil {
+		return ConflictPair{}, false, err
+	}
+	pair.DeviceName = f.DeviceNames.Name(pair.DeviceID)
+	return pair, true, nil
 }
 
-func shouldSkip(path string, skipPaths []string) bool {
-	for _, skipPath := range skipPaths {
-		if strings.Contains(path, skipPath) {
-			return true
+func (f *DefaultFileFinder) detectorsFor(path string) []ConflictDetector {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+
+	best := ""
+	for root := range f.VaultDetectors {
+		if isWithin(root, abs) && len(root) > len(best) {
+			best = root
 		}
 	}
-	return false
+	if best != "" {
+		return f.VaultDetectors[best]
+	}
+	return f.Detectors
+}
+
+func isWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
 }
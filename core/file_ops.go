@@ -0,0 +1,184 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// FileOps performs every change lessmay makes to a vault so that dry runs,
+// disposal and the undo journal only need to be handled in one place.
+type FileOps struct {
+	DryRun   bool
+	Disposer Disposer
+	Journal  *Journal
+	Out      io.Writer
+
+	changed []string
+}
+
+func (o *FileOps) out() io.Writer {
+	if o.Out == nil {
+		return os.Stdout
+	}
+	return o.Out
+}
+
+func (o *FileOps) Remove(path string) error {
+	disposer := o.Disposer
+	if disposer == nil {
+		disposer = &deleteDisposer{}
+	}
+
+	if o.DryRun {
+		fmt.Fprintf(
+			o.out(),
+			"[dry-run] would %s %s\n",
+			disposalVerb(disposer),
+			path,
+		)
+		return nil
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	var seq int
+	var backup string
+	if o.Journal != nil {
+		seq = o.Journal.nextSeq()
+		if backup, err = o.Journal.backup(seq, path); err != nil {
+			return err
+		}
+	}
+
+	target, err := disposer.Dispose(path)
+	if err != nil {
+		return err
+	}
+
+	return o.record(JournalEntry{
+		Seq:    seq,
+		Op:     JournalDispose,
+		Path:   path,
+		Target: target,
+		Backup: backup,
+	})
+}
+
+func (o *FileOps) Rename(from, to string) error {
+	if o.DryRun {
+		fmt.Fprintf(o.out(), "[dry-run] would rename %s to %s\n", from, to)
+		return nil
+	}
+
+	from, err := filepath.Abs(from)
+	if err != nil {
+		return err
+	}
+	to, err = filepath.Abs(to)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(from, to); err != nil {
+		return err
+	}
+
+	var seq int
+	if o.Journal != nil {
+		seq = o.Journal.nextSeq()
+	}
+	return o.record(JournalEntry{
+		Seq:    seq,
+		Op:     JournalRename,
+		Path:   from,
+		Target: to,
+	})
+}
+
+func (o *FileOps) WriteFile(path string, content []byte) error {
+	if o.DryRun {
+		fmt.Fprintf(
+			o.out(),
+			"[dry-run] would write %d bytes of merged content to %s\n",
+			len(content),
+			path,
+		)
+		return nil
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	var seq int
+	var backup string
+	if o.Journal != nil {
+		seq = o.Journal.nextSeq()
+		if backup, err = o.Journal.backup(seq, path); err != nil {
+			return err
+		}
+	}
+
+	if err := os.WriteFile(path, content, info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	return o.record(JournalEntry{
+		Seq:    seq,
+		Op:     JournalWrite,
+		Path:   path,
+		Backup: backup,
+	})
+}
+
+func (o *FileOps) record(entry JournalEntry) error {
+	o.changed = append(o.changed, entry.Path)
+	if entry.Target != "" {
+		o.changed = append(o.changed, entry.Target)
+	}
+	if o.Journal == nil {
+		return nil
+	}
+	if err := o.Journal.record(entry); err != nil {
+		return fmt.Errorf("error recording %s in journal: %w", entry.Path, err)
+	}
+	return nil
+}
+
+// takeChanged returns the paths changed since the last call.
+func (o *FileOps) takeChanged() []string {
+	changed := o.changed
+	o.changed = nil
+	return changed
+}
+
+func disposalVerb(disposer Disposer) string {
+	switch disposer.(type) {
+	case *vaultTrashDisposer:
+		return "move to the vault trash"
+	case *osTrashDisposer:
+		return "move to the OS trash"
+	case *quarantineDisposer:
+		return "quarantine"
+	default:
+		return "delete"
+	}
+}
+
+func opsOrDefault(ops *FileOps) *FileOps {
+	if ops == nil {
+		return &FileOps{}
+	}
+	return ops
+}
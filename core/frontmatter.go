@@ -0,0 +1,135 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// DefaultFrontmatterIgnore lists frontmatter keys that plugins rewrite on
+// every save.
+var DefaultFrontmatterIgnore = []string{"updated", "modified"}
+
+// note is a Markdown file split into its YAML frontmatter and body. Fields
+// is empty when the file has no frontmatter.
+type note struct {
+	Fields map[string]interface{}
+	Body   []byte
+}
+
+func isMarkdown(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".md")
+}
+
+// splitFrontmatter finds a frontmatter block: a first line of --- closed
+// by a line of --- or ..., as Obsidian and Jekyll write it.
+func splitFrontmatter(content []byte) (frontmatter, body []byte, ok bool) {
+	content = bytes.TrimPrefix(content, utf8BOM)
+	line, rest, found := bytes.Cut(content, []byte("\n"))
+	if !found || string(bytes.TrimRight(line, " \t\r")) != "---" {
+		return nil, content, false
+	}
+
+	for offset := 0; offset < len(rest); {
+		end := bytes.IndexByte(rest[offset:], '\n')
+		next := len(rest)
+		if end >= 0 {
+			next = offset + end + 1
+		}
+		switch string(bytes.TrimRight(rest[offset:next], " \t\r\n")) {
+		case "---", "...":
+			return rest[:offset], rest[next:], true
+		}
+		offset = next
+	}
+	return nil, content, false
+}
+
+func parseNote(content []byte) (*note, error) {
+	frontmatter, body, ok := splitFrontmatter(content)
+	n := &note{Fields: map[string]interface{}{}, Body: body}
+	if !ok {
+		return n, nil
+	}
+	if err := yaml.Unmarshal(frontmatter, &n.Fields); err != nil {
+		return nil, fmt.Errorf("error parsing frontmatter: %w", err)
+	}
+	if n.Fields == nil {
+		n.Fields = map[string]interface{}{}
+	}
+	return n, nil
+}
+
+// diffFrontmatter lists the keys whose values differ, sorted by key. Key
+// order and YAML formatting do not count. Keys in ignore, compared without
+// regard to case, are still listed but marked as ignored.
+func diffFrontmatter(conflict, original map[string]interface{}, ignore []string) []FieldChange {
+	keys := map[string]bool{}
+	for key := range conflict {
+		keys[key] = true
+	}
+	for key := range original {
+		keys[key] = true
+	}
+
+	var changes []FieldChange
+	for key := range keys {
+		oldValue, inConflict := conflict[key]
+		newValue, inOriginal := original[key]
+		if inConflict && inOriginal && reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+		changes = append(changes, FieldChange{
+			Path:    key,
+			Old:     oldValue,
+			New:     newValue,
+			Added:   !inConflict,
+			Removed: !inOriginal,
+			Ignored: ignoredKey(key, ignore),
+		})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+func ignoredKey(key string, ignore []string) bool {
+	for _, ignored := range ignore {
+		if strings.EqualFold(key, ignored) {
+			return true
+		}
+	}
+	return false
+}
+
+func meaningfulChanges(changes []FieldChange) []FieldChange {
+	var meaningful []FieldChange
+	for _, change := range changes {
+		if !change.Ignored {
+			meaningful = append(meaningful, change)
+		}
+	}
+	return meaningful
+}
+
+// notesEquivalent compares two notes by their frontmatter, leaving out the
+// ignored keys, and their bodies at the given equivalence level. Notes
+// whose frontmatter does not parse are never equivalent.
+func notesEquivalent(a, b []byte, ignore []string, level string) (bool, error) {
+	if _, err := equivalenceRank(level); err != nil {
+		return false, err
+	}
+	noteA, errA := parseNote(a)
+	noteB, errB := parseNote(b)
+	if errA != nil || errB != nil {
+		return false, nil
+	}
+	if len(meaningfulChanges(diffFrontmatter(noteA.Fields, noteB.Fields, ignore))) > 0 {
+		return false, nil
+	}
+	return equivalent(noteA.Body, noteB.Body, level)
+}
@@ -0,0 +1,190 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gkwa/lessmay/internal/diff"
+)
+
+// Variant is one version of a note in a group: the original or one of its
+// conflict copies.
+type Variant struct {
+	Path    string
+	Label   string
+	ModTime time.Time
+	Size    int64
+	// SameAs is the index of the first variant with identical content, or
+	// -1 if this is the first with its content.
+	SameAs int
+	Newest bool
+
+	content []byte
+}
+
+// GroupView shows every version of a note side by side.
+type GroupView struct {
+	OriginalPath string
+	Variants     []Variant
+	// Columns indexes the variants with distinct content; Rows line them up
+	// in that order.
+	Columns []int
+	Rows    []diff.Row
+}
+
+func NewGroupView(group ConflictGroup, opts diff.Options) (*GroupView, error) {
+	view := &GroupView{OriginalPath: group.OriginalPath}
+
+	if len(group.Pairs) > 0 && !group.Pairs[0].Orphan {
+		first := group.Pairs[0]
+		view.Variants = append(view.Variants, Variant{
+			Path:    first.OriginalPath,
+			Label:   "original",
+			ModTime: first.OriginalModTime,
+			Size:    first.OriginalSize,
+		})
+	}
+	for _, pair := range group.Pairs {
+		label := pair.DeviceName
+		if label == "" {
+			label = pair.DeviceID
+		}
+		if label == "" {
+			label = "conflict"
+		}
+		view.Variants = append(view.Variants, Variant{
+			Path:    pair.ConflictPath,
+			Label:   label,
+			ModTime: pair.ConflictModTime,
+			Size:    pair.ConflictSize,
+		})
+	}
+
+	newest := -1
+	for i := range view.Variants {
+		v := &view.Variants[i]
+		content, err := os.ReadFile(v.Path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", v.Path, err)
+		}
+		v.content = content
+		v.SameAs = -1
+		for j := 0; j < i; j++ {
+			if view.Variants[j].SameAs == -1 &&
+				bytes.Equal(view.Variants[j].content, content) {
+				v.SameAs = j
+				break
+			}
+		}
+		if v.SameAs == -1 {
+			view.Columns = append(view.Columns, i)
+		}
+		if newest == -1 || v.ModTime.After(view.Variants[newest].ModTime) {
+			newest = i
+		}
+	}
+	if newest >= 0 {
+		view.Variants[newest].Newest = true
+	}
+
+	if len(view.Columns) > 1 {
+		base := diff.SplitLines(string(view.Variants[view.Columns[0]].content))
+		var others [][]string
+		for _, col := range view.Columns[1:] {
+			others = append(
+				others,
+				diff.SplitLines(string(view.Variants[col].content)),
+			)
+		}
+		view.Rows = diff.NWay(base, others, opts)
+	}
+
+	return view, nil
+}
+
+// IdenticalTo lists the other variants with the same content as variant i.
+func (v *GroupView) IdenticalTo(i int) []string {
+	class := v.Variants[i].SameAs
+	if class == -1 {
+		class = i
+	}
+	var paths []string
+	for j, other := range v.Variants {
+		if j == i {
+			continue
+		}
+		if j == class || other.SameAs == class {
+			paths = append(paths, other.Path)
+		}
+	}
+	return paths
+}
+
+// annotate adds what the view knows about the report's conflict copy.
+func (v *GroupView) annotate(report *ConflictReport) {
+	report.GroupSize = len(v.Variants)
+	for i, variant := range v.Variants {
+		if variant.Path == report.ConflictPath {
+			report.IdenticalTo = v.IdenticalTo(i)
+			report.Newest = variant.Newest
+		}
+	}
+}
+
+// Write prints the variants and the lines on which they disagree. Lines
+// every version shares are left out.
+func (v *GroupView) Write(w io.Writer) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# variants: %s (%d)\n", v.OriginalPath, len(v.Variants))
+	for i, variant := range v.Variants {
+		fmt.Fprintf(
+			&b,
+			"  [%d] %-10s %s  %6d bytes  %s",
+			i,
+			variant.Label,
+			variant.ModTime.Format("2006-01-02 15:04:05"),
+			variant.Size,
+			filepath.Base(variant.Path),
+		)
+		if variant.SameAs >= 0 {
+			fmt.Fprintf(&b, "  = [%d]", variant.SameAs)
+		}
+		if variant.Newest {
+			b.WriteString("  newest")
+		}
+		b.WriteString("\n")
+	}
+
+	if len(v.Rows) > 0 {
+		b.WriteString("# comparison:\n ")
+		for _, col := range v.Columns {
+			fmt.Fprintf(&b, " [%d]", col)
+		}
+		b.WriteString("\n")
+
+		for _, row := range v.Rows {
+			if row.Common() {
+				continue
+			}
+			b.WriteString(" ")
+			for i, present := range row.Present {
+				mark := "."
+				if present {
+					mark = "x"
+				}
+				width := len(fmt.Sprint(v.Columns[i])) + 2
+				fmt.Fprintf(&b, " %-*s", width, " "+mark)
+			}
+			fmt.Fprintf(&b, " | %s", withNewline(row.Text))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,498 @@
+package core
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+
+	"github.com/gkwa/lessmay/internal/diff"
+)
+
+var errQuit = errors.New("quit")
+
+type InteractiveResolver struct {
+	finder   *DefaultFileFinder
+	differ   DiffRunner
+	diffOpts diff.Options
+	in       *bufio.Reader
+	out      io.Writer
+	editor   string
+	ops      *FileOps
+	rescan   Rescanner
+	uris     *URIBuilder
+	opener   *Opener
+	logger   logr.Logger
+}
+
+func NewInteractiveResolver(
+	logger logr.Logger,
+	opts Options,
+	in io.Reader,
+	out io.Writer,
+) (*InteractiveResolver, error) {
+	finder, err := opts.fileFinder(logger)
+	if err != nil {
+		return nil, err
+	}
+	ops, err := opts.fileOps(out)
+	if err != nil {
+		return nil, err
+	}
+	client, err := opts.syncthingClient()
+	if err != nil {
+		return nil, err
+	}
+	uris, err := opts.uriBuilder(logger)
+	if err != nil {
+		return nil, err
+	}
+	opener := NewOpener(opts.Opener)
+
+	return &InteractiveResolver{
+		finder: finder,
+		differ: &DefaultDiffRunner{
+			Out:            out,
+			IgnoreAllSpace: opts.IgnoreAllSpace,
+			URIs:           uris,
+			Opener:         opener,
+			Frontmatter:    opts.Frontmatter,
+			IgnoreKeys:     opts.FrontmatterIgnore,
+			SemanticJSON:   opts.SemanticJSON,
+		},
+		diffOpts: diff.Options{IgnoreAllSpace: opts.IgnoreAllSpace},
+		in:       bufio.NewReader(in),
+		out:      out,
+		editor:   defaultEditor(),
+		ops:      ops,
+		rescan:   rescannerOrNil(client),
+		uris:     uris,
+		opener:   opener,
+		logger:   logger,
+	}, nil
+}
+
+func defaultEditor() string {
+	if editor := os.Getenv("VISUAL"); editor != "" {
+		return editor
+	}
+	if editor := os.Getenv("EDITOR"); editor != "" {
+		return editor
+	}
+	if runtime.GOOS == "windows" {
+		return "notepad"
+	}
+	return "vi"
+}
+
+func (r *InteractiveResolver) Resolve(paths, skipPaths []string) error {
+	pairs, err := r.finder.FindSyncConflictFiles(paths, skipPaths)
+	if err != nil {
+		return fmt.Errorf("failed to find sync conflict files: %w", err)
+	}
+
+	if len(pairs) == 0 {
+		fmt.Fprintln(r.out, "No sync conflicts found.")
+		return nil
+	}
+	defer rescan(r.logger, r.rescan, r.ops)
+
+	index := 0
+	for _, group := range GroupConflicts(pairs) {
+		if len(group.Pairs) > 1 {
+			done, err := r.resolveGroup(group)
+			if errors.Is(err, errQuit) {
+				return nil
+			}
+			if err != nil {
+				r.logger.Error(
+					err,
+					"Failed to resolve conflict group",
+					"originalFile",
+					group.OriginalPath,
+				)
+			}
+			fmt.Fprintln(r.out)
+			if done {
+				index += len(group.Pairs)
+				continue
+			}
+		}
+		for i, pair := range group.Pairs {
+			index++
+			if i > 0 {
+				// An earlier decision may have replaced the original or
+				// consumed this copy.
+				if err := pair.stat(); err != nil {
+					continue
+				}
+			}
+
+			var err error
+			if pair.Orphan {
+				err = r.resolveOrphan(pair, index, len(pairs), paths, skipPaths)
+			} else {
+				err = r.resolvePair(pair, index, len(pairs))
+			}
+			if errors.Is(err, errQuit) {
+				return nil
+			}
+			if err != nil {
+				r.logger.Error(
+					err,
+					"Failed to resolve conflict",
+					"conflictFile",
+					pair.ConflictPath,
+					"originalFile",
+					pair.OriginalPath,
+				)
+			}
+			fmt.Fprintln(r.out)
+		}
+	}
+
+	return nil
+}
+
+// resolveGroup shows every version of a note and lets the user pick one to
+// keep. It reports whether the group was dealt with; if not, the copies are
+// gone through one at a time.
+func (r *InteractiveResolver) resolveGroup(group ConflictGroup) (bool, error) {
+	view, err := NewGroupView(group, r.diffOpts)
+	if err != nil {
+		return false, err
+	}
+	if err := view.Write(r.out); err != nil {
+		return false, err
+	}
+
+	for {
+		choice, err := r.prompt(fmt.Sprintf(
+			"[0-%d] keep that version and remove the rest, "+
+				"[Enter] one copy at a time, [s] skip, [q] quit: ",
+			len(view.Variants)-1,
+		))
+		if err != nil {
+			return false, err
+		}
+
+		switch choice {
+		case "":
+			return false, nil
+		case "s":
+			return true, nil
+		case "q":
+			return false, errQuit
+		}
+
+		winner, err := strconv.Atoi(choice)
+		if err != nil || winner < 0 || winner >= len(view.Variants) {
+			fmt.Fprintf(r.out, "Unknown choice %q\n", choice)
+			continue
+		}
+		return true, r.report(
+			r.keepVariant(group, view.Variants[winner].Path),
+			"Kept %s",
+			view.Variants[winner].Path,
+		)
+	}
+}
+
+// keepVariant makes winner the original and removes every other copy.
+func (r *InteractiveResolver) keepVariant(
+	group ConflictGroup,
+	winner string,
+) error {
+	for _, pair := range group.Pairs {
+		if pair.ConflictPath == winner {
+			continue
+		}
+		if err := r.ops.keepOriginal(pair); err != nil {
+			return err
+		}
+	}
+	for _, pair := range group.Pairs {
+		if pair.ConflictPath != winner {
+			continue
+		}
+		if pair.Orphan {
+			return r.ops.Rename(pair.ConflictPath, pair.OriginalPath)
+		}
+		return r.ops.keepConflict(pair)
+	}
+	return nil
+}
+
+func (r *InteractiveResolver) resolvePair(
+	pair ConflictPair,
+	index, total int,
+) error {
+	for {
+		fmt.Fprintf(r.out, "[%d/%d] %s\n", index, total, pair.OriginalPath)
+		fmt.Fprintf(
+			r.out,
+			"  original: %d bytes, modified %s\n",
+			pair.OriginalSize,
+			pair.OriginalModTime.Format("2006-01-02 15:04:05"),
+		)
+		fmt.Fprintf(
+			r.out,
+			"  conflict: %d bytes, modified %s, device %s\n",
+			pair.ConflictSize,
+			pair.ConflictModTime.Format("2006-01-02 15:04:05"),
+			pair.DeviceLabel(),
+		)
+
+		// Only a label here, so a file that cannot be read is left to the
+		// diff to report.
+		pair.Classification, _ = classifyPair(pair, "")
+		if err := r.differ.RunDiff(pair, index); err != nil {
+			return err
+		}
+
+		choice, err := r.prompt(
+			"[o] keep original, [c] keep conflict, [b] keep both, " +
+				"[m] merge hunks, [e] edit, [v] view in Obsidian, " +
+				"[s] skip, [q] quit: ",
+		)
+		if err != nil {
+			return err
+		}
+
+		switch choice {
+		case "o":
+			return r.report(
+				r.ops.keepOriginal(pair),
+				"Kept original, removed %s",
+				pair.ConflictPath,
+			)
+		case "c":
+			return r.report(
+				r.ops.keepConflict(pair),
+				"Replaced %s with conflict copy",
+				pair.OriginalPath,
+			)
+		case "b":
+			target, err := r.ops.keepBoth(pair)
+			return r.report(
+				err,
+				"Kept both, conflict copy renamed to %s",
+				target,
+			)
+		case "m":
+			merged, err := r.mergeHunks(pair)
+			if err != nil {
+				return err
+			}
+			if merged == nil {
+				continue
+			}
+			return r.report(
+				r.ops.writeMerged(pair, merged),
+				"Merged into %s",
+				pair.OriginalPath,
+			)
+		case "e":
+			if err := r.edit(pair); err != nil {
+				return err
+			}
+			if err := pair.stat(); err != nil {
+				return err
+			}
+		case "v":
+			for _, path := range []string{pair.OriginalPath, pair.ConflictPath} {
+				if err := r.opener.Open(r.uris.URI(path)); err != nil {
+					fmt.Fprintf(r.out, "Could not open %s: %v\n", path, err)
+				}
+			}
+		case "s":
+			return nil
+		case "q":
+			return errQuit
+		default:
+			fmt.Fprintf(r.out, "Unknown choice %q\n", choice)
+		}
+	}
+}
+
+func (r *InteractiveResolver) resolveOrphan(
+	pair ConflictPair,
+	index, total int,
+	roots, skipPaths []string,
+) error {
+	fmt.Fprintf(r.out, "[%d/%d] %s\n", index, total, pair.ConflictPath)
+	fmt.Fprintf(r.out, "  original is missing: %s\n", pair.OriginalPath)
+
+	renamed, err := findRenamedOriginal(pair, roots, skipPaths, r.finder)
+	if err != nil {
+		return err
+	}
+	if renamed != "" {
+		fmt.Fprintf(r.out, "  same content found in: %s\n", renamed)
+	}
+
+	for {
+		choice, err := r.prompt(
+			"[p] promote to original name, [x] remove conflict copy, " +
+				"[s] skip, [q] quit: ",
+		)
+		if err != nil {
+			return err
+		}
+
+		switch choice {
+		case "p":
+			return r.report(
+				r.ops.Rename(pair.ConflictPath, pair.OriginalPath),
+				"Promoted %s to %s",
+				pair.ConflictPath,
+				pair.OriginalPath,
+			)
+		case "x":
+			return r.report(
+				r.ops.keepOriginal(pair),
+				"Removed %s",
+				pair.ConflictPath,
+			)
+		case "s":
+			return nil
+		case "q":
+			return errQuit
+		default:
+			fmt.Fprintf(r.out, "Unknown choice %q\n", choice)
+		}
+	}
+}
+
+func (r *InteractiveResolver) report(
+	err error,
+	format string,
+	args ...any,
+) error {
+	if err != nil {
+		return err
+	}
+	if r.ops.DryRun {
+		return nil
+	}
+	fmt.Fprintf(r.out, format+"\n", args...)
+	return nil
+}
+
+func (r *InteractiveResolver) prompt(question string) (string, error) {
+	fmt.Fprint(r.out, question)
+	line, err := r.in.ReadString('\n')
+	if errors.Is(err, io.EOF) && line == "" {
+		return "", errQuit
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.ToLower(strings.TrimSpace(line)), nil
+}
+
+// mergeHunks walks every changed block and asks which side to keep. It
+// returns nil if the merge was abandoned.
+func (r *InteractiveResolver) mergeHunks(pair ConflictPair) ([]byte, error) {
+	conflictContent, err := os.ReadFile(pair.ConflictPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading conflict file: %w", err)
+	}
+	originalContent, err := os.ReadFile(pair.OriginalPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading original file: %w", err)
+	}
+
+	conflictLines := diff.SplitLines(string(conflictContent))
+	originalLines := diff.SplitLines(string(originalContent))
+	blocks := diff.Blocks(
+		diff.Compute(conflictLines, originalLines, diff.Options{}),
+	)
+
+	var merged []string
+	prev := 0
+	for i, blk := range blocks {
+		merged = append(merged, originalLines[prev:blk.B0]...)
+		prev = blk.B1
+
+		fmt.Fprintf(r.out, "hunk %d/%d\n", i+1, len(blocks))
+		for _, line := range conflictLines[blk.A0:blk.A1] {
+			fmt.Fprintf(r.out, "-%s", withNewline(line))
+		}
+		for _, line := range originalLines[blk.B0:blk.B1] {
+			fmt.Fprintf(r.out, "+%s", withNewline(line))
+		}
+
+		for {
+			choice, err := r.prompt(
+				"[o] original (+), [c] conflict (-), [b] both, [a] abort merge: ",
+			)
+			if err != nil {
+				return nil, err
+			}
+
+			switch choice {
+			case "o":
+				merged = append(merged, originalLines[blk.B0:blk.B1]...)
+			case "c":
+				merged = append(merged, conflictLines[blk.A0:blk.A1]...)
+			case "b":
+				merged = append(merged, originalLines[blk.B0:blk.B1]...)
+				merged = append(merged, conflictLines[blk.A0:blk.A1]...)
+			case "a":
+				return nil, nil
+			default:
+				fmt.Fprintf(r.out, "Unknown choice %q\n", choice)
+				continue
+			}
+			break
+		}
+	}
+	merged = append(merged, originalLines[prev:]...)
+
+	return []byte(joinLines(merged)), nil
+}
+
+func (r *InteractiveResolver) edit(pair ConflictPair) error {
+	fields := strings.Fields(r.editor)
+	if len(fields) == 0 {
+		return fmt.Errorf("no editor configured")
+	}
+
+	args := append(fields[1:], pair.OriginalPath, pair.ConflictPath)
+	cmd := exec.Command(fields[0], args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error running editor %s: %w", r.editor, err)
+	}
+	return nil
+}
+
+func withNewline(line string) string {
+	if strings.HasSuffix(line, "\n") {
+		return line
+	}
+	return line + "\n"
+}
+
+// joinLines glues lines back together, adding a newline where a line that
+// used to end the file is no longer last.
+func joinLines(lines []string) string {
+	var sb strings.Builder
+	for i, line := range lines {
+		sb.WriteString(line)
+		if i < len(lines)-1 && !strings.HasSuffix(line, "\n") {
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
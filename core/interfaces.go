@@ -1,13 +1,23 @@
 package core
 
 type FileFinder interface {
-	FindSyncConflictFiles(paths, skipPaths []string) ([]string, error)
+	FindSyncConflictFiles(paths, skipPaths []string) ([]ConflictPair, error)
 }
 
 type DiffRunner interface {
-	RunDiff(conflictFile, originalFile string, count int) error
+	RunDiff(pair ConflictPair, count int) error
 }
 
 type FileComparer interface {
-	CompareAndDelete(conflictFile, originalFile string) (bool, error)
+	CompareAndDelete(pair ConflictPair) (bool, error)
+}
+
+type Merger interface {
+	Merge(pair ConflictPair) (MergeResult, error)
+}
+
+// Rescanner is told which paths lessmay changed so that the sync engine can
+// pick the changes up straight away.
+type Rescanner interface {
+	Rescan(paths []string) error
 }
@@ -0,0 +1,171 @@
+package core
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+const (
+	journalFile = "journal.ndjson"
+	backupsDir  = "backups"
+)
+
+const (
+	JournalDispose = "dispose"
+	JournalRename  = "rename"
+	JournalWrite   = "write"
+	JournalUndo    = "undo"
+)
+
+type JournalEntry struct {
+	Run    string    `json:"run"`
+	Seq    int       `json:"seq"`
+	Time   time.Time `json:"time"`
+	Op     string    `json:"op"`
+	Path   string    `json:"path,omitempty"`
+	Target string    `json:"target,omitempty"`
+	Backup string    `json:"backup,omitempty"`
+}
+
+// Journal appends one entry per change to an NDJSON file and keeps a copy
+// of any content that is about to be removed or overwritten.
+type Journal struct {
+	dir   string
+	runID string
+	seq   int
+	mu    sync.Mutex
+}
+
+func NewJournal(dir string) (*Journal, error) {
+	dir, err := journalDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+
+	return &Journal{
+		dir: dir,
+		runID: time.Now().Format("20060102-150405") + "-" +
+			hex.EncodeToString(suffix),
+	}, nil
+}
+
+// journalDir returns dir with ~ expanded, or the state directory if dir is
+// empty.
+func journalDir(dir string) (string, error) {
+	if dir == "" {
+		return stateDir()
+	}
+	expanded, err := homedir.Expand(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to expand path %s: %w", dir, err)
+	}
+	return expanded, nil
+}
+
+func (j *Journal) RunID() string {
+	return j.runID
+}
+
+func (j *Journal) Path() string {
+	return filepath.Join(j.dir, journalFile)
+}
+
+func (j *Journal) nextSeq() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.seq++
+	return j.seq
+}
+
+func (j *Journal) backup(seq int, path string) (string, error) {
+	dir := filepath.Join(j.dir, backupsDir, j.runID)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("error creating backup directory: %w", err)
+	}
+	target := filepath.Join(dir, fmt.Sprintf("%04d-%s", seq, filepath.Base(path)))
+	if err := copyFile(path, target); err != nil {
+		return "", fmt.Errorf("error backing up %s: %w", path, err)
+	}
+	return target, nil
+}
+
+func (j *Journal) record(entry JournalEntry) error {
+	entry.Run = j.runID
+	entry.Time = time.Now()
+	return appendJournal(j.dir, entry)
+}
+
+func appendJournal(dir string, entry JournalEntry) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("error creating journal directory: %w", err)
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(
+		filepath.Join(dir, journalFile),
+		os.O_WRONLY|os.O_CREATE|os.O_APPEND,
+		0o600,
+	)
+	if err != nil {
+		return fmt.Errorf("error opening journal: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing journal: %w", err)
+	}
+	return f.Close()
+}
+
+func ReadJournal(dir string) ([]JournalEntry, error) {
+	dir, err := journalDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(filepath.Join(dir, journalFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening journal: %w", err)
+	}
+	defer f.Close()
+
+	var entries []JournalEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("error parsing journal line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading journal: %w", err)
+	}
+	return entries, nil
+}
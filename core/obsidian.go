@@ -0,0 +1,158 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// ObsidianVault is a vault registered in Obsidian's obsidian.json.
+type ObsidianVault struct {
+	ID   string
+	Name string
+	Path string
+	Open bool
+	// Missing is set when the vault's directory no longer exists; Obsidian
+	// keeps vaults deleted on disk in its list.
+	Missing bool
+}
+
+type obsidianRegistry struct {
+	Vaults map[string]struct {
+		Path string `json:"path"`
+		Open bool   `json:"open"`
+	} `json:"vaults"`
+}
+
+// ObsidianConfigPaths lists where Obsidian keeps obsidian.json on this
+// platform, including the flatpak and snap packages on Linux.
+func ObsidianConfigPaths() []string {
+	home, err := homedir.Dir()
+	if err != nil {
+		return nil
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{
+			filepath.Join(home, "Library", "Application Support", "obsidian", "obsidian.json"),
+		}
+	case "windows":
+		appData := os.Getenv("APPDATA")
+		if appData == "" {
+			appData = filepath.Join(home, "AppData", "Roaming")
+		}
+		return []string{filepath.Join(appData, "obsidian", "obsidian.json")}
+	}
+
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(home, ".config")
+	}
+	return []string{
+		filepath.Join(configHome, "obsidian", "obsidian.json"),
+		filepath.Join(home, ".var", "app", "md.obsidian.Obsidian", "config", "obsidian", "obsidian.json"),
+		filepath.Join(home, "snap", "obsidian", "current", ".config", "obsidian", "obsidian.json"),
+	}
+}
+
+func ReadObsidianVaults(configPath string) ([]ObsidianVault, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var registry obsidianRegistry
+	if err := json.Unmarshal(data, &registry); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", configPath, err)
+	}
+
+	vaults := make([]ObsidianVault, 0, len(registry.Vaults))
+	for id, vault := range registry.Vaults {
+		_, err := os.Stat(vault.Path)
+		vaults = append(vaults, ObsidianVault{
+			ID:      id,
+			Name:    filepath.Base(vault.Path),
+			Path:    vault.Path,
+			Open:    vault.Open,
+			Missing: errors.Is(err, fs.ErrNotExist),
+		})
+	}
+	return vaults, nil
+}
+
+// FindObsidianVaults reads configPath, or every obsidian.json in the usual
+// places when it is empty. Vaults are sorted by name, and one registered
+// by several installs is listed once.
+func FindObsidianVaults(configPath string) ([]ObsidianVault, error) {
+	paths := ObsidianConfigPaths()
+	if configPath != "" {
+		expanded, err := homedir.Expand(configPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand path %s: %w", configPath, err)
+		}
+		paths = []string{expanded}
+	}
+
+	var vaults []ObsidianVault
+	seen := map[string]bool{}
+	for _, path := range paths {
+		found, err := ReadObsidianVaults(path)
+		if errors.Is(err, fs.ErrNotExist) && configPath == "" {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Obsidian vault registry: %w", err)
+		}
+		for _, vault := range found {
+			if seen[vault.Path] {
+				continue
+			}
+			seen[vault.Path] = true
+			vaults = append(vaults, vault)
+		}
+	}
+
+	sort.Slice(vaults, func(i, j int) bool {
+		if vaults[i].Name != vaults[j].Name {
+			return vaults[i].Name < vaults[j].Name
+		}
+		return vaults[i].Path < vaults[j].Path
+	})
+	return vaults, nil
+}
+
+// SelectVaults picks vaults by name, ignoring case, or by Obsidian's vault
+// ID.
+func SelectVaults(vaults []ObsidianVault, names []string) ([]ObsidianVault, error) {
+	var selected []ObsidianVault
+	for _, name := range names {
+		found := false
+		for _, vault := range vaults {
+			if strings.EqualFold(vault.Name, name) || vault.ID == name {
+				selected = append(selected, vault)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no Obsidian vault named %q", name)
+		}
+	}
+	return selected, nil
+}
+
+func GetDefaultObsidianPath() (string, error) {
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", fmt.Errorf("error getting home directory: %w", err)
+	}
+	return filepath.Join(home, "Documents", "Obsidian Vault"), nil
+}
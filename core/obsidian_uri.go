@@ -0,0 +1,144 @@
+package core
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const (
+	// URIFormVault links by vault name and path within the vault, which
+	// keeps working when the vault lives elsewhere on another machine.
+	URIFormVault = "vault"
+	// URIFormPath links by absolute path.
+	URIFormPath = "path"
+)
+
+var URIForms = []string{URIFormVault, URIFormPath}
+
+// URIBuilder makes obsidian://open links. Files outside any known vault
+// always get the path= form.
+type URIBuilder struct {
+	Form   string
+	Vaults []ObsidianVault
+}
+
+func NewURIBuilder(form string, vaults []ObsidianVault) (*URIBuilder, error) {
+	switch form {
+	case "", URIFormVault, URIFormPath:
+	default:
+		return nil, fmt.Errorf(
+			"unknown URI form %q, expected one of %s",
+			form,
+			strings.Join(URIForms, ", "),
+		)
+	}
+	return &URIBuilder{Form: form, Vaults: vaults}, nil
+}
+
+func (b *URIBuilder) URI(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+
+	if b == nil || b.Form != URIFormPath {
+		if vault, rel, ok := b.vaultFor(abs); ok {
+			// Obsidian's own "Copy Obsidian URL" leaves off .md.
+			rel = strings.TrimSuffix(filepath.ToSlash(rel), ".md")
+			return "obsidian://open?vault=" + encodeURIComponent(vault) +
+				"&file=" + encodeURIComponent(rel)
+		}
+	}
+	return "obsidian://open?path=" + encodeURIComponent(filepath.ToSlash(abs))
+}
+
+// vaultFor finds the vault holding path: the innermost registered one, or
+// failing that the nearest directory with an .obsidian folder.
+func (b *URIBuilder) vaultFor(path string) (string, string, bool) {
+	var name, root string
+	if b != nil {
+		for _, vault := range b.Vaults {
+			if isWithin(vault.Path, path) && len(vault.Path) > len(root) {
+				name, root = vault.Name, vault.Path
+			}
+		}
+	}
+	if root == "" {
+		root = findAncestorWith(filepath.Dir(path), ".obsidian")
+		name = filepath.Base(root)
+	}
+	if root == "" {
+		return "", "", false
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return "", "", false
+	}
+	return name, rel, true
+}
+
+// encodeURIComponent percent-encodes everything but RFC 3986 unreserved
+// characters, so spaces become %20 rather than +, and / # & ? are escaped.
+func encodeURIComponent(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0xf])
+	}
+	return b.String()
+}
+
+// Opener hands URIs to the desktop.
+type Opener struct {
+	Command []string
+}
+
+// NewOpener splits command on spaces; an empty command picks open on
+// macOS, rundll32 url.dll,FileProtocolHandler on Windows and xdg-open
+// elsewhere.
+func NewOpener(command string) *Opener {
+	if fields := strings.Fields(command); len(fields) > 0 {
+		return &Opener{Command: fields}
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		return &Opener{Command: []string{"open"}}
+	case "windows":
+		return &Opener{Command: []string{"rundll32", "url.dll,FileProtocolHandler"}}
+	default:
+		return &Opener{Command: []string{"xdg-open"}}
+	}
+}
+
+func (o *Opener) Open(uri string) error {
+	args := append(o.Command[1:len(o.Command):len(o.Command)], uri)
+	cmd := exec.Command(o.Command[0], args...)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error running %s: %w", o.Command[0], err)
+	}
+	return cmd.Process.Release()
+}
+
+// CommandLine is the shell command that would open uri, for copying.
+func (o *Opener) CommandLine(uri string) string {
+	return strings.Join(o.Command, " ") + " " + shellQuote(uri)
+}
+
+func shellQuote(s string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + s + `"`
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
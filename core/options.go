@@ -0,0 +1,167 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/go-logr/logr"
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+type Options struct {
+	IgnoreAllSpace bool
+	Merge          bool
+	DryRun         bool
+	Disposal       string
+	QuarantineDir  string
+	JournalDir     string
+	Output         string
+	Detectors      []string
+	VaultDetectors map[string][]string
+	Hostnames      []string
+	OrphanAction   string
+	SubsetPolicy   string
+	NoStignore     bool
+	SkipRegexes    []string
+	Include        []string
+	// SyncthingConfig is read for device names; empty means Syncthing's
+	// default location.
+	SyncthingConfig string
+	Devices         map[string]string
+	// Syncthing turns on the REST API: its folders become the default scan
+	// roots and changed directories are rescanned. Address and API key
+	// default to those in the Syncthing config.
+	Syncthing        bool
+	SyncthingAddress string
+	SyncthingAPIKey  string
+	// ObsidianConfig is the obsidian.json to read registered vaults from;
+	// empty means Obsidian's own locations.
+	ObsidianConfig string
+	Vaults         []string
+	URIForm        string
+	Opener         string
+	Equivalence    string
+	// Frontmatter compares Markdown frontmatter key by key, leaving out
+	// FrontmatterIgnore, and the body separately.
+	Frontmatter       bool
+	FrontmatterIgnore []string
+	// SemanticJSON compares .json and .canvas files by their data and,
+	// with Merge, merges them field by field.
+	SemanticJSON bool
+	// AppendOnly lists globs, relative to the vault, of notes that are
+	// merged when both sides only appended lines. AppendOnlyUnversioned
+	// also merges notes with no older copy in .stversions when both sides
+	// added lines after a blank line or at a list item.
+	AppendOnly            []string
+	AppendOnlyUnversioned bool
+}
+
+func (o Options) fileOps(out io.Writer) (*FileOps, error) {
+	disposer, err := NewDisposer(o.Disposal, o.QuarantineDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var journal *Journal
+	if !o.DryRun {
+		journal, err = NewJournal(o.JournalDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &FileOps{
+		DryRun:   o.DryRun,
+		Disposer: disposer,
+		Journal:  journal,
+		Out:      out,
+	}, nil
+}
+
+func (o Options) syncthingClient() (*SyncthingClient, error) {
+	if !o.Syncthing {
+		return nil, nil
+	}
+
+	address, apiKey, tls := o.SyncthingAddress, o.SyncthingAPIKey, false
+	if address == "" || apiKey == "" {
+		config, err := loadSyncthingConfig(o.SyncthingConfig)
+		if err != nil {
+			return nil, err
+		}
+		if config != nil {
+			if address == "" {
+				address, tls = config.GUI.Address, config.GUI.TLS
+			}
+			if apiKey == "" {
+				apiKey = config.GUI.APIKey
+			}
+		}
+	}
+	if apiKey == "" {
+		return nil, fmt.Errorf(
+			"no Syncthing API key: set syncthing-api-key or point syncthing-config at Syncthing's config.xml",
+		)
+	}
+
+	return NewSyncthingClient(address, apiKey, tls)
+}
+
+// uriBuilder names files by vault where Obsidian's registry can be read. An
+// unreadable registry only costs the vault form: every file then gets the
+// path form.
+func (o Options) uriBuilder(logger logr.Logger) (*URIBuilder, error) {
+	vaults, err := FindObsidianVaults(o.ObsidianConfig)
+	if err != nil {
+		logger.Error(err, "Cannot read Obsidian vaults, linking files by path")
+		vaults = nil
+	}
+	return NewURIBuilder(o.URIForm, vaults)
+}
+
+func (o Options) fileFinder(logger logr.Logger) (*DefaultFileFinder, error) {
+	detectors, err := NewDetectors(o.Detectors, o.Hostnames)
+	if err != nil {
+		return nil, err
+	}
+
+	skipRegexes, err := compileRegexes(o.SkipRegexes)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := compileGlobs(o.Include); err != nil {
+		return nil, err
+	}
+
+	syncthingConfig, err := loadSyncthingConfig(o.SyncthingConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	vaultDetectors := map[string][]ConflictDetector{}
+	for vault, names := range o.VaultDetectors {
+		expanded, err := homedir.Expand(vault)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand path %s: %w", vault, err)
+		}
+		abs, err := filepath.Abs(expanded)
+		if err != nil {
+			return nil, err
+		}
+		vaultDetectors[abs], err = NewDetectors(names, o.Hostnames)
+		if err != nil {
+			return nil, fmt.Errorf("detectors for %s: %w", vault, err)
+		}
+	}
+
+	return &DefaultFileFinder{
+		Detectors:      detectors,
+		VaultDetectors: vaultDetectors,
+		NoStignore:     o.NoStignore,
+		SkipRegexes:    skipRegexes,
+		Include:        o.Include,
+		DeviceNames:    NewDeviceNames(syncthingConfig, o.Devices),
+		Logger:         logger,
+	}, nil
+}
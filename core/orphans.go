@@ -0,0 +1,103 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	OrphanReport      = "report"
+	OrphanPromote     = "promote"
+	OrphanFindRenamed = "find-renamed"
+)
+
+var OrphanActions = []string{OrphanReport, OrphanPromote, OrphanFindRenamed}
+
+func validateOrphanAction(action string) error {
+	switch action {
+	case "", OrphanReport, OrphanPromote, OrphanFindRenamed:
+		return nil
+	default:
+		return fmt.Errorf(
+			"unknown orphan action %q, expected one of %s",
+			action,
+			strings.Join(OrphanActions, ", "),
+		)
+	}
+}
+
+// findRenamedOriginal looks under roots for a file with exactly the same
+// content as the orphaned conflict copy, which is where the original most
+// likely went.
+func findRenamedOriginal(
+	pair ConflictPair,
+	roots, skipPaths []string,
+	finder *DefaultFileFinder,
+) (string, error) {
+	want, err := fileHash(pair.ConflictPath)
+	if err != nil {
+		return "", err
+	}
+
+	if finder == nil {
+		finder = &DefaultFileFinder{}
+	}
+
+	var found string
+	for _, root := range roots {
+		err := finder.walk(
+			root,
+			skipPaths,
+			func(path string, d fs.DirEntry) error {
+				if d.IsDir() || path == pair.ConflictPath {
+					return nil
+				}
+				if _, _, ok := detect(path, finder.detectorsFor(path)); ok {
+					return nil
+				}
+
+				info, err := d.Info()
+				if err != nil || info.Size() != pair.ConflictSize {
+					return nil
+				}
+				got, err := fileHash(path)
+				if err != nil {
+					return nil
+				}
+				if bytes.Equal(got, want) {
+					found = path
+					return filepath.SkipAll
+				}
+				return nil
+			},
+		)
+		if err != nil {
+			return "", fmt.Errorf("error searching %s: %w", root, err)
+		}
+		if found != "" {
+			return found, nil
+		}
+	}
+
+	return "", nil
+}
+
+func fileHash(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
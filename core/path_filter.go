@@ -0,0 +1,137 @@
+package core
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// pathFilter decides which paths below a scan root the finder looks at.
+// Skip and include patterns are gitignore-style globs relative to the root:
+// a pattern without a slash matches a name at any depth, one with a slash
+// is anchored at the root, and a trailing slash only matches directories.
+type pathFilter struct {
+	root        string
+	skip        []globPattern
+	skipRegexes []*regexp.Regexp
+	include     []globPattern
+	ignore      *Stignore
+}
+
+type globPattern struct {
+	re      *regexp.Regexp
+	dirOnly bool
+}
+
+func compileGlob(pattern string) (globPattern, error) {
+	p := filepath.ToSlash(pattern)
+	dirOnly := strings.HasSuffix(p, "/")
+	p = strings.TrimSuffix(p, "/")
+
+	switch {
+	case p == "":
+		return globPattern{}, fmt.Errorf("empty pattern %q", pattern)
+	case strings.HasPrefix(p, "/"):
+		p = p[1:]
+	case !strings.Contains(p, "/") && !strings.HasPrefix(p, "**"):
+		p = "**/" + p
+	}
+	// **/ also matches nothing at all, as in .gitignore.
+	p = strings.ReplaceAll(p, "**/", "{**/,}")
+
+	re, err := globRegexp(p, false)
+	if err != nil {
+		return globPattern{}, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	return globPattern{re: re, dirOnly: dirOnly}, nil
+}
+
+func compileGlobs(patterns []string) ([]globPattern, error) {
+	globs := make([]globPattern, 0, len(patterns))
+	for _, pattern := range patterns {
+		glob, err := compileGlob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		globs = append(globs, glob)
+	}
+	return globs, nil
+}
+
+func compileRegexes(patterns []string) ([]*regexp.Regexp, error) {
+	regexes := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid skip regex %q: %w", pattern, err)
+		}
+		regexes = append(regexes, re)
+	}
+	return regexes, nil
+}
+
+// check reports whether path is left out, and for a directory whether
+// its contents can be left unvisited too.
+func (f *pathFilter) check(path string, isDir bool) (skip, skipDir bool) {
+	rel, ok := f.rel(path)
+	if !ok {
+		return false, false
+	}
+
+	if f.skipped(rel, isDir) {
+		return true, isDir
+	}
+	if f.ignore.Match(path) {
+		return true, isDir && f.ignore.canSkipDir()
+	}
+	if !isDir && len(f.include) > 0 && !matchesAny(f.include, rel, false) {
+		return true, false
+	}
+	return false, false
+}
+
+// skipped reports whether rel or any directory above it matches a skip
+// pattern.
+func (f *pathFilter) skipped(rel string, isDir bool) bool {
+	segments := strings.Split(rel, "/")
+	for i := range segments {
+		prefix := strings.Join(segments[:i+1], "/")
+		dir := isDir || i < len(segments)-1
+		if matchesAny(f.skip, prefix, dir) {
+			return true
+		}
+		for _, re := range f.skipRegexes {
+			if re.MatchString(prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchesAny(globs []globPattern, rel string, isDir bool) bool {
+	for _, glob := range globs {
+		if glob.dirOnly && !isDir {
+			continue
+		}
+		if glob.re.MatchString(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// rel returns path relative to the root with forward slashes. The root
+// itself and paths outside it are never filtered.
+func (f *pathFilter) rel(path string) (string, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil || !isWithin(f.root, abs) {
+		return "", false
+	}
+	rel, err := filepath.Rel(f.root, abs)
+	if err != nil || rel == "." {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
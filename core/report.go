@@ -0,0 +1,138 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/gkwa/lessmay/internal/diff"
+)
+
+const (
+	OutputText   = "text"
+	OutputJSON   = "json"
+	OutputNDJSON = "ndjson"
+)
+
+var OutputFormats = []string{OutputText, OutputJSON, OutputNDJSON}
+
+const (
+	ActionDeletedIdentical = "deleted-identical"
+	ActionMerged           = "merged"
+	ActionDiffering        = "differing"
+	ActionError            = "error"
+	ActionOrphaned         = "orphaned"
+	ActionPromoted         = "promoted"
+	ActionFoundRenamed     = "found-renamed"
+	ActionDeletedSubset    = "deleted-subset"
+	ActionReplacedOriginal = "replaced-with-superset"
+)
+
+type DiffStats struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+}
+
+type ConflictReport struct {
+	ConflictPath string `json:"conflictPath"`
+	OriginalPath string `json:"originalPath"`
+	DeviceID     string `json:"deviceId,omitempty"`
+	DeviceName   string `json:"deviceName,omitempty"`
+	Action       string `json:"action"`
+	// Classification is one of the Class constants.
+	Classification string     `json:"classification,omitempty"`
+	DryRun         bool       `json:"dryRun,omitempty"`
+	DiffStats      *DiffStats `json:"diffStats,omitempty"`
+	RenamedTo      string     `json:"renamedTo,omitempty"`
+	OriginalURI    string     `json:"originalUri,omitempty"`
+	ConflictURI    string     `json:"conflictUri,omitempty"`
+	GroupSize      int        `json:"groupSize,omitempty"`
+	IdenticalTo    []string   `json:"identicalTo,omitempty"`
+	Newest         bool       `json:"newest,omitempty"`
+	// Reason says why the mergers left a differing conflict alone.
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type Reporter interface {
+	Report(report ConflictReport) error
+	Flush() error
+}
+
+func NewReporter(format string, out io.Writer) (Reporter, error) {
+	if out == nil {
+		out = os.Stdout
+	}
+
+	switch format {
+	case "", OutputText:
+		return nil, nil
+	case OutputJSON:
+		return &jsonReporter{out: out}, nil
+	case OutputNDJSON:
+		return &ndjsonReporter{enc: json.NewEncoder(out)}, nil
+	default:
+		return nil, fmt.Errorf(
+			"unknown output format %q, expected one of %s",
+			format,
+			strings.Join(OutputFormats, ", "),
+		)
+	}
+}
+
+type jsonReporter struct {
+	out     io.Writer
+	reports []ConflictReport
+}
+
+func (r *jsonReporter) Report(report ConflictReport) error {
+	r.reports = append(r.reports, report)
+	return nil
+}
+
+func (r *jsonReporter) Flush() error {
+	reports := r.reports
+	if reports == nil {
+		reports = []ConflictReport{}
+	}
+	r.reports = nil
+
+	enc := json.NewEncoder(r.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(reports)
+}
+
+type ndjsonReporter struct {
+	enc *json.Encoder
+}
+
+func (r *ndjsonReporter) Report(report ConflictReport) error {
+	return r.enc.Encode(report)
+}
+
+func (r *ndjsonReporter) Flush() error {
+	return nil
+}
+
+func computeDiffStats(pair ConflictPair, opts diff.Options) (*DiffStats, error) {
+	conflictContent, err := os.ReadFile(pair.ConflictPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading conflict file: %w", err)
+	}
+	originalContent, err := os.ReadFile(pair.OriginalPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading original file: %w", err)
+	}
+	if isBinary(conflictContent) || isBinary(originalContent) {
+		return nil, nil
+	}
+
+	stats := diff.ComputeStats(diff.Compute(
+		diff.SplitLines(string(conflictContent)),
+		diff.SplitLines(string(originalContent)),
+		opts,
+	))
+	return &DiffStats{Added: stats.Added, Removed: stats.Removed}, nil
+}
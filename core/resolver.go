@@ -1,26 +1,122 @@
 package core
 
 import (
+	"errors"
 	"fmt"
-	"regexp"
+	"os"
+	"strings"
 
 	"github.com/go-logr/logr"
+
+	"github.com/gkwa/lessmay/internal/diff"
 )
 
 type SyncConflictResolver struct {
 	finder   FileFinder
 	differ   DiffRunner
 	comparer FileComparer
+	mergers  []Merger
+	ops      *FileOps
+	reporter Reporter
+	rescan   Rescanner
+	uris     *URIBuilder
+	diffOpts diff.Options
 	logger   logr.Logger
+
+	orphanAction string
+	subsetPolicy string
+	equivalence  string
+	roots        []string
+	skipPaths    []string
+	orphans      []ConflictPair
 }
 
-func NewSyncConflictResolver(logger logr.Logger) *SyncConflictResolver {
+func NewSyncConflictResolver(
+	logger logr.Logger,
+	opts Options,
+) (*SyncConflictResolver, error) {
+	reporter, err := NewReporter(opts.Output, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateOrphanAction(opts.OrphanAction); err != nil {
+		return nil, err
+	}
+	if err := validateSubsetPolicy(opts.SubsetPolicy); err != nil {
+		return nil, err
+	}
+	if _, err := equivalenceRank(opts.Equivalence); err != nil {
+		return nil, err
+	}
+
+	// Keep stdout clean for machine-readable reports.
+	opsOut := os.Stdout
+	if reporter != nil {
+		opsOut = os.Stderr
+	}
+	ops, err := opts.fileOps(opsOut)
+	if err != nil {
+		return nil, err
+	}
+	finder, err := opts.fileFinder(logger)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := opts.syncthingClient()
+	if err != nil {
+		return nil, err
+	}
+
+	uris, err := opts.uriBuilder(logger)
+	if err != nil {
+		return nil, err
+	}
+
+	var mergers []Merger
+	if len(opts.AppendOnly) > 0 {
+		appendOnly, err := NewAppendOnlyMerger(opts.AppendOnly, opts.AppendOnlyUnversioned)
+		if err != nil {
+			return nil, err
+		}
+		mergers = append(mergers, appendOnly)
+	}
+	if opts.Merge && opts.SemanticJSON {
+		mergers = append(mergers, &JSONMerger{})
+	}
+	if opts.Merge {
+		mergers = append(mergers, &ThreeWayMerger{})
+	}
+
 	return &SyncConflictResolver{
-		finder:   &DefaultFileFinder{},
-		differ:   &DefaultDiffRunner{},
-		comparer: &DefaultFileComparer{},
+		finder: finder,
+		differ: &DefaultDiffRunner{
+			IgnoreAllSpace: opts.IgnoreAllSpace,
+			URIs:           uris,
+			Opener:         NewOpener(opts.Opener),
+			Frontmatter:    opts.Frontmatter,
+			IgnoreKeys:     opts.FrontmatterIgnore,
+			SemanticJSON:   opts.SemanticJSON,
+		},
+		comparer: &DefaultFileComparer{
+			Ops:          ops,
+			Equivalence:  opts.Equivalence,
+			Frontmatter:  opts.Frontmatter,
+			IgnoreKeys:   opts.FrontmatterIgnore,
+			SemanticJSON: opts.SemanticJSON,
+		},
+		mergers:  mergers,
+		ops:      ops,
+		reporter: reporter,
+		rescan:   rescannerOrNil(client),
+		uris:     uris,
+		diffOpts: diff.Options{IgnoreAllSpace: opts.IgnoreAllSpace},
 		logger:   logger,
-	}
+
+		orphanAction: opts.OrphanAction,
+		subsetPolicy: opts.SubsetPolicy,
+		equivalence:  opts.Equivalence,
+	}, nil
 }
 
 func (r *SyncConflictResolver) ResolveSyncConflicts(
@@ -28,42 +124,431 @@ func (r *SyncConflictResolver) ResolveSyncConflicts(
 ) error {
 	r.logger.V(1).Info("Starting sync conflict resolution")
 
-	conflictFiles, err := r.finder.FindSyncConflictFiles(paths, skipPaths)
+	r.roots = paths
+	r.skipPaths = skipPaths
+
+	pairs, err := r.finder.FindSyncConflictFiles(paths, skipPaths)
 	if err != nil {
 		return fmt.Errorf("failed to find sync conflict files: %w", err)
 	}
 
-	for i, conflictFile := range conflictFiles {
-		originalFile := regexp.MustCompile(`\.sync-conflict-\d{8}-\d{6}-\w+\.md$`).
-			ReplaceAllString(conflictFile, ".md")
+	count := 0
+	for _, group := range GroupConflicts(pairs) {
+		if err := r.resolveGroup(group, &count); err != nil {
+			return err
+		}
+	}
+
+	if err := r.flush(); err != nil {
+		return err
+	}
+
+	r.logger.V(1).Info("Finished sync conflict resolution")
+	return nil
+}
 
-		deleted, err := r.comparer.CompareAndDelete(conflictFile, originalFile)
+// resolveGroup handles every conflict copy of one original. When there is
+// more than one, the variants are shown together first, and reports note
+// which copies match each other.
+func (r *SyncConflictResolver) resolveGroup(
+	group ConflictGroup,
+	count *int,
+) error {
+	var view *GroupView
+	if len(group.Pairs) > 1 {
+		v, err := NewGroupView(group, r.diffOpts)
 		if err != nil {
 			r.logger.Error(
 				err,
-				"Failed to compare and delete files",
+				"Failed to compare conflict copies",
+				"originalFile",
+				group.OriginalPath,
+			)
+		} else {
+			view = v
+		}
+	}
+	if view != nil && r.reporter == nil {
+		if err := view.Write(os.Stdout); err != nil {
+			return err
+		}
+		fmt.Println()
+	}
+
+	for i, pair := range group.Pairs {
+		if i > 0 {
+			// Earlier copies may have replaced or removed the original.
+			if err := pair.stat(); err != nil {
+				r.logger.V(1).Info(
+					"Skipping conflict file that changed during resolution",
+					"conflictFile",
+					pair.ConflictPath,
+					"error",
+					err.Error(),
+				)
+				continue
+			}
+		}
+		*count++
+		if err := r.resolveAndReport(pair, *count, view); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flush writes out what has been collected so far: the machine-readable
+// report, or in text mode the list of orphans, which are shown apart from
+// the diffs.
+func (r *SyncConflictResolver) flush() error {
+	rescan(r.logger, r.rescan, r.ops)
+
+	if r.reporter != nil {
+		if err := r.reporter.Flush(); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+		return nil
+	}
+
+	if len(r.orphans) == 0 {
+		return nil
+	}
+	fmt.Printf("# orphaned conflicts (original missing): %d\n", len(r.orphans))
+	for _, pair := range r.orphans {
+		fmt.Printf("%s\n", pair.ConflictPath)
+		fmt.Printf("  missing original: %s\n", pair.OriginalPath)
+	}
+	fmt.Println()
+	r.orphans = nil
+	return nil
+}
+
+func (r *SyncConflictResolver) resolveAndReport(
+	pair ConflictPair,
+	count int,
+	view *GroupView,
+) error {
+	report := r.resolvePair(pair, count)
+	if view != nil {
+		view.annotate(&report)
+	}
+	if r.reporter == nil {
+		if report.Action != ActionOrphaned {
+			fmt.Println()
+		}
+		return nil
+	}
+	if err := r.reporter.Report(report); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+func (r *SyncConflictResolver) resolvePair(
+	pair ConflictPair,
+	count int,
+) ConflictReport {
+	dryRun := opsOrDefault(r.ops).DryRun
+	report := ConflictReport{
+		ConflictPath: pair.ConflictPath,
+		OriginalPath: pair.OriginalPath,
+		DeviceID:     pair.DeviceID,
+		DeviceName:   pair.DeviceName,
+		DryRun:       dryRun,
+		ConflictURI:  r.uris.URI(pair.ConflictPath),
+	}
+	if !pair.Orphan {
+		report.OriginalURI = r.uris.URI(pair.OriginalPath)
+	}
+
+	if pair.Orphan {
+		return r.resolveOrphan(pair, report)
+	}
+
+	deleted, err := r.comparer.CompareAndDelete(pair)
+	if err != nil {
+		r.logger.Error(
+			err,
+			"Failed to compare and delete files",
+			"conflictFile",
+			pair.ConflictPath,
+			"originalFile",
+			pair.OriginalPath,
+		)
+		report.Action = ActionError
+		report.Error = err.Error()
+		return report
+	}
+
+	if deleted {
+		r.logger.Info(
+			"Deleted identical sync conflict file",
+			"conflictFile",
+			pair.ConflictPath,
+			"dryRun",
+			dryRun,
+		)
+		report.Action = ActionDeletedIdentical
+		report.Classification = ClassIdentical
+		return report
+	}
+
+	// Classifying is informational, so a failure leaves the pair to the
+	// usual diff.
+	pair.Classification, err = classifyPair(pair, r.equivalence)
+	if err != nil {
+		r.logger.V(1).Info(
+			"Could not classify sync conflict file",
+			"conflictFile",
+			pair.ConflictPath,
+			"error",
+			err.Error(),
+		)
+	}
+	report.Classification = pair.Classification
+
+	action, err := r.applySubsetPolicy(pair)
+	if err != nil && action == "" {
+		r.logger.Error(
+			err,
+			"Failed to apply subset policy",
+			"conflictFile",
+			pair.ConflictPath,
+			"originalFile",
+			pair.OriginalPath,
+		)
+		report.Action = ActionError
+		report.Error = err.Error()
+		return report
+	}
+	if action != "" {
+		if err != nil {
+			r.logger.Error(
+				err,
+				"Failed to remove sync conflict file after replacing original",
 				"conflictFile",
-				conflictFile,
+				pair.ConflictPath,
+			)
+			report.Error = err.Error()
+		}
+		report.Action = action
+		return report
+	}
+
+	if r.reporter != nil {
+		stats, err := computeDiffStats(pair, r.diffOpts)
+		if err != nil {
+			report.Action = ActionError
+			report.Error = err.Error()
+			return report
+		}
+		report.DiffStats = stats
+	}
+
+	merged, reason, err := r.merge(pair)
+	if merged {
+		report.Action = ActionMerged
+		if err != nil {
+			report.Error = err.Error()
+			return report
+		}
+		r.logger.Info(
+			"Merged sync conflict file into original",
+			"conflictFile",
+			pair.ConflictPath,
+			"originalFile",
+			pair.OriginalPath,
+			"dryRun",
+			dryRun,
+		)
+		return report
+	}
+
+	report.Action = ActionDiffering
+	report.Reason = reason
+	if r.reporter != nil {
+		return report
+	}
+
+	if err := r.differ.RunDiff(pair, count); err != nil {
+		r.logger.Error(
+			err,
+			"Failed to run diff",
+			"conflictFile",
+			pair.ConflictPath,
+			"originalFile",
+			pair.OriginalPath,
+		)
+		report.Action = ActionError
+		report.Error = err.Error()
+	}
+	return report
+}
+
+func (r *SyncConflictResolver) resolveOrphan(
+	pair ConflictPair,
+	report ConflictReport,
+) ConflictReport {
+	ops := opsOrDefault(r.ops)
+
+	switch r.orphanAction {
+	case OrphanPromote:
+		if err := ops.Rename(pair.ConflictPath, pair.OriginalPath); err != nil {
+			report.Action = ActionError
+			report.Error = err.Error()
+			return report
+		}
+		r.logger.Info(
+			"Promoted orphaned sync conflict file to original name",
+			"conflictFile",
+			pair.ConflictPath,
+			"originalFile",
+			pair.OriginalPath,
+			"dryRun",
+			ops.DryRun,
+		)
+		report.Action = ActionPromoted
+		return report
+
+	case OrphanFindRenamed:
+		finder, _ := r.finder.(*DefaultFileFinder)
+		renamed, err := findRenamedOriginal(pair, r.roots, r.skipPaths, finder)
+		if err != nil {
+			report.Action = ActionError
+			report.Error = err.Error()
+			return report
+		}
+		if renamed != "" {
+			if err := ops.Remove(pair.ConflictPath); err != nil {
+				report.Action = ActionError
+				report.Error = err.Error()
+				return report
+			}
+			r.logger.Info(
+				"Deleted orphaned sync conflict file identical to renamed original",
+				"conflictFile",
+				pair.ConflictPath,
+				"renamedTo",
+				renamed,
+				"dryRun",
+				ops.DryRun,
+			)
+			report.Action = ActionFoundRenamed
+			report.RenamedTo = renamed
+			return report
+		}
+	}
+
+	r.logger.V(1).Info(
+		"Original of sync conflict file is missing",
+		"conflictFile",
+		pair.ConflictPath,
+		"originalFile",
+		pair.OriginalPath,
+	)
+	r.orphans = append(r.orphans, pair)
+	report.Action = ActionOrphaned
+	return report
+}
+
+// applySubsetPolicy keeps the superset of a pair whose lines are all in the
+// other file, as far as the policy allows, and returns the action taken.
+func (r *SyncConflictResolver) applySubsetPolicy(pair ConflictPair) (string, error) {
+	ops := opsOrDefault(r.ops)
+
+	switch {
+	case pair.Classification == ClassConflictSubset &&
+		(r.subsetPolicy == SubsetRemoveConflict || r.subsetPolicy == SubsetKeepSuperset):
+		if err := ops.Remove(pair.ConflictPath); err != nil {
+			return "", fmt.Errorf("error deleting conflict file: %w", err)
+		}
+		r.logger.Info(
+			"Deleted sync conflict file contained in original",
+			"conflictFile",
+			pair.ConflictPath,
+			"dryRun",
+			ops.DryRun,
+		)
+		return ActionDeletedSubset, nil
+
+	case pair.Classification == ClassOriginalSubset && r.subsetPolicy == SubsetKeepSuperset:
+		content, err := os.ReadFile(pair.ConflictPath)
+		if err != nil {
+			return "", fmt.Errorf("error reading conflict file: %w", err)
+		}
+		if err := ops.writeMerged(pair, content); err != nil {
+			if errors.Is(err, errConflictKept) {
+				return ActionReplacedOriginal, err
+			}
+			return "", err
+		}
+		r.logger.Info(
+			"Replaced original with sync conflict file containing it",
+			"conflictFile",
+			pair.ConflictPath,
+			"originalFile",
+			pair.OriginalPath,
+			"dryRun",
+			ops.DryRun,
+		)
+		return ActionReplacedOriginal, nil
+	}
+	return "", nil
+}
+
+// merge tries each merger in turn. When none succeeds it returns why,
+// one "strategy: reason" per merger that looked at the pair. A merge that
+// reached the original still counts as merged if the conflict copy could
+// not be removed; the error says so.
+func (r *SyncConflictResolver) merge(pair ConflictPair) (bool, string, error) {
+	var reasons []string
+	for _, merger := range r.mergers {
+		result, err := merger.Merge(pair)
+		if err != nil {
+			r.logger.Error(
+				err,
+				"Failed to merge files",
+				"conflictFile",
+				pair.ConflictPath,
 				"originalFile",
-				originalFile,
+				pair.OriginalPath,
 			)
+			reasons = append(reasons, result.Strategy+": "+err.Error())
 			continue
 		}
 
-		if deleted {
+		if !result.Clean && result.Reason == "" {
+			// The merger does not handle this kind of file.
+			continue
+		}
+		if !result.Clean {
 			r.logger.Info(
-				"Deleted identical sync conflict file",
+				"Could not merge sync conflict file",
 				"conflictFile",
-				conflictFile,
+				pair.ConflictPath,
+				"strategy",
+				result.Strategy,
+				"reason",
+				result.Reason,
 			)
-		} else {
-			if err := r.differ.RunDiff(conflictFile, originalFile, i+1); err != nil {
-				r.logger.Error(err, "Failed to run diff", "conflictFile", conflictFile, "originalFile", originalFile)
+			reasons = append(reasons, result.Strategy+": "+result.Reason)
+			continue
+		}
+
+		if err := opsOrDefault(r.ops).writeMerged(pair, result.Content); err != nil {
+			r.logger.Error(
+				err,
+				"Failed to write merged file",
+				"originalFile",
+				pair.OriginalPath,
+			)
+			if errors.Is(err, errConflictKept) {
+				return true, "", err
 			}
+			reasons = append(reasons, result.Strategy+": "+err.Error())
+			return false, strings.Join(reasons, "; "), nil
 		}
-		fmt.Println()
+		return true, "", nil
 	}
-
-	r.logger.V(1).Info("Finished sync conflict resolution")
-	return nil
+	return false, strings.Join(reasons, "; "), nil
 }
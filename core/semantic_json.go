@@ -0,0 +1,557 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FieldChange is one field that differs between a conflict copy and its
+// original: a frontmatter key or the JSON path of a value. Old is the
+// conflict copy's value, New the original's.
+type FieldChange struct {
+	Path    string
+	Old     interface{}
+	New     interface{}
+	Added   bool
+	Removed bool
+	Ignored bool
+}
+
+func (c FieldChange) String() string {
+	var s string
+	switch {
+	case c.Added:
+		s = fmt.Sprintf("+ %s: %s", c.Path, encodeJSON(c.New, ""))
+	case c.Removed:
+		s = fmt.Sprintf("- %s: %s", c.Path, encodeJSON(c.Old, ""))
+	default:
+		s = fmt.Sprintf("~ %s: %s -> %s", c.Path, encodeJSON(c.Old, ""), encodeJSON(c.New, ""))
+	}
+	if c.Ignored {
+		s += "  (ignored)"
+	}
+	return s
+}
+
+func isJSONDocument(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".canvas":
+		return true
+	}
+	return false
+}
+
+// mergesByID reports whether arrays of objects carrying an "id" are
+// matched up by id rather than position, as for the nodes and edges of a
+// canvas.
+func mergesByID(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".canvas")
+}
+
+// jsonObject is a decoded JSON object that remembers its key order, so
+// that merged files come out the way Obsidian wrote them.
+type jsonObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (o *jsonObject) set(key string, value interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+// decodeJSON decodes a document into *jsonObject, []interface{},
+// json.Number, string, bool and nil values.
+func decodeJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	value, err := decodeJSONValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+	return value, nil
+}
+
+func decodeJSONValue(dec *json.Decoder) (interface{}, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token {
+	case json.Delim('{'):
+		object := &jsonObject{values: map[string]interface{}{}}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			object.set(key.(string), value)
+		}
+		_, err := dec.Token()
+		return object, err
+	case json.Delim('['):
+		array := []interface{}{}
+		for dec.More() {
+			value, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		_, err := dec.Token()
+		return array, err
+	}
+	return token, nil
+}
+
+// encodeJSON writes value like JavaScript's JSON.stringify, which is what
+// Obsidian uses: compact when indent is empty, and without escaping <, >
+// and &.
+func encodeJSON(value interface{}, indent string) []byte {
+	var buf bytes.Buffer
+	writeJSONValue(&buf, value, "", indent)
+	return buf.Bytes()
+}
+
+func writeJSONValue(buf *bytes.Buffer, value interface{}, prefix, indent string) {
+	separator := ":"
+	if indent != "" {
+		separator = ": "
+	}
+	open := func(i int) {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if indent != "" {
+			buf.WriteString("\n" + prefix + indent)
+		}
+	}
+	closing := func() {
+		if indent != "" {
+			buf.WriteString("\n" + prefix)
+		}
+	}
+
+	switch v := value.(type) {
+	case *jsonObject:
+		if len(v.keys) == 0 {
+			buf.WriteString("{}")
+			return
+		}
+		buf.WriteByte('{')
+		for i, key := range v.keys {
+			open(i)
+			writeJSONValue(buf, key, "", "")
+			buf.WriteString(separator)
+			writeJSONValue(buf, v.values[key], prefix+indent, indent)
+		}
+		closing()
+		buf.WriteByte('}')
+	case []interface{}:
+		if len(v) == 0 {
+			buf.WriteString("[]")
+			return
+		}
+		buf.WriteByte('[')
+		for i, element := range v {
+			open(i)
+			writeJSONValue(buf, element, prefix+indent, indent)
+		}
+		closing()
+		buf.WriteByte(']')
+	default:
+		var scalar bytes.Buffer
+		enc := json.NewEncoder(&scalar)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(v); err != nil {
+			fmt.Fprint(buf, v)
+			return
+		}
+		buf.Write(bytes.TrimSuffix(scalar.Bytes(), []byte("\n")))
+	}
+}
+
+// jsonLayout returns the indentation of a document, empty when it is on one
+// line, and whether it ends with a newline.
+func jsonLayout(data []byte) (string, bool) {
+	trimmed := bytes.TrimRight(data, " \t\r\n")
+	newline := len(trimmed) < len(data) && data[len(data)-1] == '\n'
+
+	_, rest, found := bytes.Cut(trimmed, []byte("\n"))
+	if !found {
+		return "", newline
+	}
+	indent := rest[:len(rest)-len(bytes.TrimLeft(rest, " \t"))]
+	if len(indent) == 0 {
+		return "  ", newline
+	}
+	return string(indent), newline
+}
+
+func jsonEqual(a, b interface{}) bool {
+	switch a := a.(type) {
+	case *jsonObject:
+		b, ok := b.(*jsonObject)
+		if !ok || len(a.values) != len(b.values) {
+			return false
+		}
+		for key, value := range a.values {
+			other, ok := b.values[key]
+			if !ok || !jsonEqual(value, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !jsonEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		if a == b {
+			return true
+		}
+		x, errA := a.Float64()
+		y, errB := b.Float64()
+		return errA == nil && errB == nil && x == y
+	}
+	return a == b
+}
+
+// jsonEquivalent reports whether two documents hold the same data, in any
+// key order or formatting. Documents that do not parse are never
+// equivalent.
+func jsonEquivalent(a, b []byte) bool {
+	docA, err := decodeJSON(a)
+	if err != nil {
+		return false
+	}
+	docB, err := decodeJSON(b)
+	if err != nil {
+		return false
+	}
+	return jsonEqual(docA, docB)
+}
+
+var jsonIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+func jsonPathKey(path, key string) string {
+	if jsonIdentifier.MatchString(key) {
+		return path + "." + key
+	}
+	return path + "[" + string(encodeJSON(key, "")) + "]"
+}
+
+func jsonPathIndex(path string, i int) string {
+	return path + "[" + strconv.Itoa(i) + "]"
+}
+
+func jsonPathID(path, id string) string {
+	return path + "[id=" + id + "]"
+}
+
+// elementsByID indexes an array whose elements are all objects with a
+// string id, keeping the order of the array.
+func elementsByID(array []interface{}) ([]string, map[string]interface{}, bool) {
+	ids := make([]string, 0, len(array))
+	byID := make(map[string]interface{}, len(array))
+	for _, element := range array {
+		object, ok := element.(*jsonObject)
+		if !ok {
+			return nil, nil, false
+		}
+		id, ok := object.values["id"].(string)
+		if !ok {
+			return nil, nil, false
+		}
+		if _, dup := byID[id]; dup {
+			return nil, nil, false
+		}
+		ids = append(ids, id)
+		byID[id] = element
+	}
+	return ids, byID, true
+}
+
+// diffJSON lists the JSON paths at which conflict and original differ.
+func diffJSON(path string, conflict, original interface{}, byID bool) []FieldChange {
+	if jsonEqual(conflict, original) {
+		return nil
+	}
+
+	switch c := conflict.(type) {
+	case *jsonObject:
+		o, ok := original.(*jsonObject)
+		if !ok {
+			break
+		}
+		var changes []FieldChange
+		for _, key := range o.keys {
+			if _, ok := c.values[key]; !ok {
+				changes = append(changes, FieldChange{
+					Path:  jsonPathKey(path, key),
+					New:   o.values[key],
+					Added: true,
+				})
+			}
+		}
+		for _, key := range c.keys {
+			keyPath := jsonPathKey(path, key)
+			value, ok := o.values[key]
+			if !ok {
+				changes = append(changes, FieldChange{
+					Path:    keyPath,
+					Old:     c.values[key],
+					Removed: true,
+				})
+				continue
+			}
+			changes = append(changes, diffJSON(keyPath, c.values[key], value, byID)...)
+		}
+		return changes
+
+	case []interface{}:
+		o, ok := original.([]interface{})
+		if !ok {
+			break
+		}
+		if byID {
+			conflictIDs, conflictByID, okC := elementsByID(c)
+			originalIDs, originalByID, okO := elementsByID(o)
+			if okC && okO {
+				var changes []FieldChange
+				for _, id := range originalIDs {
+					if _, ok := conflictByID[id]; !ok {
+						changes = append(changes, FieldChange{
+							Path:  jsonPathID(path, id),
+							New:   originalByID[id],
+							Added: true,
+						})
+					}
+				}
+				for _, id := range conflictIDs {
+					value, ok := originalByID[id]
+					if !ok {
+						changes = append(changes, FieldChange{
+							Path:    jsonPathID(path, id),
+							Old:     conflictByID[id],
+							Removed: true,
+						})
+						continue
+					}
+					changes = append(changes, diffJSON(jsonPathID(path, id), conflictByID[id], value, byID)...)
+				}
+				return changes
+			}
+		}
+		var changes []FieldChange
+		for i := 0; i < len(c) || i < len(o); i++ {
+			switch {
+			case i >= len(c):
+				changes = append(changes, FieldChange{Path: jsonPathIndex(path, i), New: o[i], Added: true})
+			case i >= len(o):
+				changes = append(changes, FieldChange{Path: jsonPathIndex(path, i), Old: c[i], Removed: true})
+			default:
+				changes = append(changes, diffJSON(jsonPathIndex(path, i), c[i], o[i], byID)...)
+			}
+		}
+		return changes
+	}
+
+	return []FieldChange{{Path: path, Old: conflict, New: original}}
+}
+
+// jsonMerge merges the conflict copy into the original field by field.
+// With a base, a side that left a value as it was in the base gives way to
+// the side that changed it, including deleting it. Without one, fields
+// that only one side has are kept. Values both sides changed differently
+// are returned as conflicting paths.
+type jsonMerge struct {
+	byID      bool
+	conflicts []string
+}
+
+func (m *jsonMerge) merge(path string, base, original, conflict interface{}, hasBase bool) interface{} {
+	if jsonEqual(original, conflict) {
+		return original
+	}
+	if hasBase && jsonEqual(base, original) {
+		return conflict
+	}
+	if hasBase && jsonEqual(base, conflict) {
+		return original
+	}
+
+	switch o := original.(type) {
+	case *jsonObject:
+		c, ok := conflict.(*jsonObject)
+		if !ok {
+			break
+		}
+		b, _ := base.(*jsonObject)
+		merged := &jsonObject{values: map[string]interface{}{}}
+		m.mergeFields(path, b, o.keys, o.values, c.keys, c.values, jsonPathKey, merged.set)
+		return merged
+
+	case []interface{}:
+		c, ok := conflict.([]interface{})
+		if !ok || !m.byID {
+			break
+		}
+		originalIDs, originalByID, okO := elementsByID(o)
+		conflictIDs, conflictByID, okC := elementsByID(c)
+		if !okO || !okC {
+			break
+		}
+		var b *jsonObject
+		if baseArray, ok := base.([]interface{}); ok {
+			if baseIDs, baseByID, ok := elementsByID(baseArray); ok {
+				b = &jsonObject{keys: baseIDs, values: baseByID}
+			}
+		}
+		merged := []interface{}{}
+		m.mergeFields(path, b, originalIDs, originalByID, conflictIDs, conflictByID, jsonPathID,
+			func(_ string, value interface{}) { merged = append(merged, value) })
+		return merged
+	}
+
+	m.conflicts = append(m.conflicts, path)
+	return original
+}
+
+// mergeFields merges the keys of two objects, or the ids of two arrays,
+// in the original's order followed by those only the conflict copy has.
+func (m *jsonMerge) mergeFields(
+	path string,
+	base *jsonObject,
+	originalKeys []string, original map[string]interface{},
+	conflictKeys []string, conflict map[string]interface{},
+	childPath func(string, string) string,
+	set func(string, interface{}),
+) {
+	keys := append([]string{}, originalKeys...)
+	for _, key := range conflictKeys {
+		if _, ok := original[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	for _, key := range keys {
+		o, inOriginal := original[key]
+		c, inConflict := conflict[key]
+		var b interface{}
+		inBase := false
+		if base != nil {
+			b, inBase = base.values[key]
+		}
+
+		switch {
+		case inOriginal && inConflict:
+			set(key, m.merge(childPath(path, key), b, o, c, inBase))
+		case !inBase:
+			// Added on one side.
+			if inOriginal {
+				set(key, o)
+			} else {
+				set(key, c)
+			}
+		case inOriginal && jsonEqual(b, o), inConflict && jsonEqual(b, c):
+			// Deleted on one side, untouched on the other.
+		default:
+			m.conflicts = append(m.conflicts, childPath(path, key))
+			if inOriginal {
+				set(key, o)
+			}
+		}
+	}
+}
+
+// JSONMerger merges JSON documents such as .obsidian settings and .canvas
+// files by their structure rather than their lines, using the same
+// .stversions base as ThreeWayMerger when there is one.
+type JSONMerger struct{}
+
+func (m *JSONMerger) Merge(pair ConflictPair) (MergeResult, error) {
+	result := MergeResult{Strategy: "json"}
+	if !isJSONDocument(pair.OriginalPath) {
+		return result, nil
+	}
+
+	originalContent, err := os.ReadFile(pair.OriginalPath)
+	if err != nil {
+		return result, fmt.Errorf("error reading original file: %w", err)
+	}
+	conflictContent, err := os.ReadFile(pair.ConflictPath)
+	if err != nil {
+		return result, fmt.Errorf("error reading conflict file: %w", err)
+	}
+	original, err := decodeJSON(originalContent)
+	if err != nil {
+		result.Reason = fmt.Sprintf("%s is not valid JSON: %v", pair.OriginalPath, err)
+		return result, nil
+	}
+	conflict, err := decodeJSON(conflictContent)
+	if err != nil {
+		result.Reason = fmt.Sprintf("%s is not valid JSON: %v", pair.ConflictPath, err)
+		return result, nil
+	}
+
+	var base interface{}
+	basePath, err := findMergeBase(pair)
+	if err != nil {
+		return result, err
+	}
+	if basePath != "" {
+		baseContent, err := os.ReadFile(basePath)
+		if err != nil {
+			return result, fmt.Errorf("error reading %s: %w", basePath, err)
+		}
+		// An unreadable base is no worse than none.
+		base, err = decodeJSON(baseContent)
+		if err != nil {
+			basePath = ""
+		}
+	}
+
+	merge := &jsonMerge{byID: mergesByID(pair.OriginalPath)}
+	merged := merge.merge("$", base, original, conflict, basePath != "")
+	if len(merge.conflicts) > 0 {
+		result.Reason = "both sides changed " + strings.Join(merge.conflicts, ", ")
+		return result, nil
+	}
+
+	indent, newline := jsonLayout(originalContent)
+	result.Content = encodeJSON(merged, indent)
+	if newline {
+		result.Content = append(result.Content, '\n')
+	}
+	result.Clean = true
+	return result, nil
+}
@@ -1,9 +1,10 @@
 package core
 
 import (
+	"context"
 	"fmt"
-	"os"
-	"path/filepath"
+	"io"
+	"time"
 
 	"github.com/go-logr/logr"
 	homedir "github.com/mitchellh/go-homedir"
@@ -14,40 +15,157 @@ func ShowConflicts(
 	args []string,
 	defaultObsidianPath string,
 	skipPaths []string,
+	opts Options,
 ) error {
-	paths, err := getConflictPaths(args, defaultObsidianPath)
+	paths, err := getConflictPaths(logger, args, defaultObsidianPath, opts)
 	if err != nil {
 		return fmt.Errorf("failed to get conflict paths: %w", err)
 	}
 
-	resolver := NewSyncConflictResolver(logger)
+	resolver, err := NewSyncConflictResolver(logger, opts)
+	if err != nil {
+		return err
+	}
 	return resolver.ResolveSyncConflicts(paths, skipPaths)
 }
 
+func Watch(
+	ctx context.Context,
+	logger logr.Logger,
+	args []string,
+	defaultObsidianPath string,
+	skipPaths []string,
+	opts Options,
+	debounce time.Duration,
+) error {
+	paths, err := getConflictPaths(logger, args, defaultObsidianPath, opts)
+	if err != nil {
+		return fmt.Errorf("failed to get conflict paths: %w", err)
+	}
+
+	watcher, err := NewWatcher(logger, opts, skipPaths, debounce)
+	if err != nil {
+		return err
+	}
+	return watcher.Run(ctx, paths)
+}
+
+// getConflictPaths expands the paths given on the command line. Without
+// any, it scans, in order of preference: the vaults picked with --vault,
+// the Syncthing folders when the API is in use, the default path if one was
+// given, and otherwise every vault Obsidian knows about that still exists,
+// falling back to ~/Documents/Obsidian Vault.
 func getConflictPaths(
+	logger logr.Logger,
 	args []string,
 	defaultObsidianPath string,
+	opts Options,
 ) ([]string, error) {
 	var paths []string
-	if len(args) == 0 {
-		paths = append(paths, defaultObsidianPath)
-	} else {
-		for _, arg := range args {
-			expandedPath, err := homedir.Expand(arg)
-			if err != nil {
-				return nil, fmt.Errorf("failed to expand path %s: %w", arg, err)
-			}
-			paths = append(paths, expandedPath)
+	for _, arg := range args {
+		expandedPath, err := homedir.Expand(arg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand path %s: %w", arg, err)
 		}
+		paths = append(paths, expandedPath)
+	}
+	if len(paths) > 0 {
+		return paths, nil
 	}
-	return paths, nil
+
+	if len(opts.Vaults) > 0 {
+		vaults, err := FindObsidianVaults(opts.ObsidianConfig)
+		if err != nil {
+			return nil, err
+		}
+		selected, err := SelectVaults(vaults, opts.Vaults)
+		if err != nil {
+			return nil, err
+		}
+		return vaultPaths(selected), nil
+	}
+
+	client, err := opts.syncthingClient()
+	if err != nil {
+		return nil, err
+	}
+	if client != nil {
+		folders, err := client.Folders()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list Syncthing folders: %w", err)
+		}
+		for _, folder := range folders {
+			paths = append(paths, folder.Path)
+		}
+		return paths, nil
+	}
+
+	if defaultObsidianPath != "" {
+		expandedPath, err := homedir.Expand(defaultObsidianPath)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"failed to expand path %s: %w",
+				defaultObsidianPath,
+				err,
+			)
+		}
+		return []string{expandedPath}, nil
+	}
+
+	vaults, err := FindObsidianVaults(opts.ObsidianConfig)
+	if err != nil {
+		return nil, err
+	}
+	var existing []ObsidianVault
+	for _, vault := range vaults {
+		if vault.Missing {
+			logger.V(1).Info(
+				"Skipping registered vault that no longer exists",
+				"vault",
+				vault.Name,
+				"path",
+				vault.Path,
+			)
+			continue
+		}
+		existing = append(existing, vault)
+	}
+	if len(existing) > 0 {
+		return vaultPaths(existing), nil
+	}
+
+	path, err := GetDefaultObsidianPath()
+	if err != nil {
+		return nil, err
+	}
+	return []string{path}, nil
 }
 
-func GetDefaultObsidianPath() string {
-	home, err := homedir.Dir()
+func vaultPaths(vaults []ObsidianVault) []string {
+	paths := make([]string, 0, len(vaults))
+	for _, vault := range vaults {
+		paths = append(paths, vault.Path)
+	}
+	return paths
+}
+
+func ResolveInteractively(
+	logger logr.Logger,
+	args []string,
+	defaultObsidianPath string,
+	skipPaths []string,
+	opts Options,
+	in io.Reader,
+	out io.Writer,
+) error {
+	paths, err := getConflictPaths(logger, args, defaultObsidianPath, opts)
+	if err != nil {
+		return fmt.Errorf("failed to get conflict paths: %w", err)
+	}
+
+	resolver, err := NewInteractiveResolver(logger, opts, in, out)
 	if err != nil {
-		fmt.Println("Error getting home directory:", err)
-		os.Exit(1)
+		return err
 	}
-	return filepath.Join(home, "Documents", "Obsidian Vault")
+	return resolver.Resolve(paths, skipPaths)
 }
@@ -0,0 +1,251 @@
+package core
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+const StignoreFile = ".stignore"
+
+// Syncthing never syncs its own bookkeeping at the folder root, whatever
+// .stignore says.
+var stInternalFiles = []string{".stfolder", StignoreFile, ".stversions"}
+
+// Stignore matches paths the way Syncthing applies a folder's .stignore:
+// patterns are tried in order and the first match decides.
+type Stignore struct {
+	root     string
+	patterns []ignorePattern
+	negated  bool
+}
+
+type ignorePattern struct {
+	re      *regexp.Regexp
+	include bool
+}
+
+// LoadStignore reads the ignore patterns of the Syncthing folder containing
+// path. The folder root is the nearest directory with a .stfolder marker,
+// or path itself. It returns nil if there is no folder and no .stignore.
+func LoadStignore(path string) (*Stignore, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	root := findAncestorWith(abs, ".stfolder")
+	if root == "" {
+		if _, err := os.Stat(filepath.Join(abs, StignoreFile)); err != nil {
+			return nil, nil
+		}
+		root = abs
+	}
+
+	s := &Stignore{root: root}
+	for _, name := range stInternalFiles {
+		if err := s.add("/"+name, false, false); err != nil {
+			return nil, err
+		}
+	}
+	// Only a missing .stignore means no patterns; a missing #include file
+	// is an error, as in Syncthing, since the patterns after it would be
+	// lost.
+	file := filepath.Join(root, StignoreFile)
+	if _, err := os.Stat(file); errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err := s.parse(file, map[string]bool{}); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Stignore) parse(file string, seen map[string]bool) error {
+	if seen[file] {
+		return fmt.Errorf("%s: include loop", file)
+	}
+	seen[file] = true
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		switch {
+		case line == "", strings.HasPrefix(line, "//"):
+			continue
+		case strings.HasPrefix(line, "#include"):
+			name := strings.TrimSpace(strings.TrimPrefix(line, "#include"))
+			if name == "" {
+				return fmt.Errorf("%s: #include without a file name", file)
+			}
+			included := filepath.Join(filepath.Dir(file), filepath.FromSlash(name))
+			if err := s.parse(included, seen); err != nil {
+				return fmt.Errorf("%s: %w", file, err)
+			}
+			continue
+		}
+
+		include, foldCase := false, runtime.GOOS == "darwin" || runtime.GOOS == "windows"
+	prefixes:
+		for {
+			switch {
+			case strings.HasPrefix(line, "!"):
+				include = true
+				line = line[1:]
+			case strings.HasPrefix(line, "(?i)"):
+				foldCase = true
+				line = line[4:]
+			case strings.HasPrefix(line, "(?d)"):
+				line = line[4:]
+			default:
+				break prefixes
+			}
+		}
+		if line == "" {
+			continue
+		}
+		if err := s.add(line, include, foldCase); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// add expands a pattern the way Syncthing does: a leading slash anchors it
+// at the folder root, otherwise it matches at any depth, and a match on a
+// directory covers everything inside it.
+func (s *Stignore) add(pattern string, include, foldCase bool) error {
+	var globs []string
+	switch {
+	case strings.HasPrefix(pattern, "/"):
+		globs = []string{pattern[1:]}
+	case strings.HasPrefix(pattern, "**/"):
+		globs = []string{pattern}
+	default:
+		globs = []string{pattern, "**/" + pattern}
+	}
+
+	for _, glob := range globs {
+		for _, g := range []string{glob, glob + "/**"} {
+			re, err := globRegexp(g, foldCase)
+			if err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+			s.patterns = append(s.patterns, ignorePattern{re: re, include: include})
+		}
+	}
+	if include {
+		s.negated = true
+	}
+	return nil
+}
+
+// Match reports whether path, which may be relative to the working
+// directory, is ignored. Paths outside the folder never are.
+func (s *Stignore) Match(path string) bool {
+	if s == nil {
+		return false
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil || !isWithin(s.root, abs) {
+		return false
+	}
+	rel, err := filepath.Rel(s.root, abs)
+	if err != nil || rel == "." {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+
+	for _, p := range s.patterns {
+		if p.re.MatchString(rel) {
+			return !p.include
+		}
+	}
+	return false
+}
+
+// canSkipDir reports whether an ignored directory can be left unvisited.
+// With negated patterns something inside it may still be included.
+func (s *Stignore) canSkipDir() bool {
+	return s == nil || !s.negated
+}
+
+// globRegexp translates a Syncthing-style glob: * and ? stay inside one path
+// segment, ** crosses segments, and [...] and {a,b} work as in a shell.
+func globRegexp(glob string, foldCase bool) (*regexp.Regexp, error) {
+	var b strings.Builder
+	if foldCase {
+		b.WriteString("(?i)")
+	}
+	b.WriteString("^")
+
+	depth := 0
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return nil, errors.New("unterminated character class")
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '{':
+			depth++
+			b.WriteString("(?:")
+		case '}':
+			if depth == 0 {
+				b.WriteString(`\}`)
+				continue
+			}
+			depth--
+			b.WriteString(")")
+		case ',':
+			if depth > 0 {
+				b.WriteString("|")
+			} else {
+				b.WriteString(",")
+			}
+		case '\\':
+			if runtime.GOOS != "windows" && i+1 < len(glob) {
+				i++
+				b.WriteString(regexp.QuoteMeta(string(glob[i])))
+			} else {
+				b.WriteString("/")
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	if depth != 0 {
+		return nil, errors.New("unterminated alternation")
+	}
+
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
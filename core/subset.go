@@ -0,0 +1,94 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gkwa/lessmay/internal/diff"
+)
+
+// Classifications say how the lines of a conflict copy relate to those of
+// its original.
+const (
+	ClassIdentical      = "identical"
+	ClassConflictSubset = "conflict-is-subset"
+	ClassOriginalSubset = "original-is-subset"
+	ClassDivergent      = "divergent"
+)
+
+const (
+	// SubsetReport only classifies conflicts.
+	SubsetReport = "report"
+	// SubsetRemoveConflict removes conflict copies whose lines are all
+	// still in the original.
+	SubsetRemoveConflict = "remove-conflict"
+	// SubsetKeepSuperset also replaces an original whose lines are all in
+	// the conflict copy with the conflict copy.
+	SubsetKeepSuperset = "keep-superset"
+)
+
+var SubsetPolicies = []string{SubsetReport, SubsetRemoveConflict, SubsetKeepSuperset}
+
+func validateSubsetPolicy(policy string) error {
+	switch policy {
+	case "", SubsetReport, SubsetRemoveConflict, SubsetKeepSuperset:
+		return nil
+	default:
+		return fmt.Errorf(
+			"unknown subset policy %q, expected one of %s",
+			policy,
+			strings.Join(SubsetPolicies, ", "),
+		)
+	}
+}
+
+func classifyPair(pair ConflictPair, level string) (string, error) {
+	conflictContent, err := os.ReadFile(pair.ConflictPath)
+	if err != nil {
+		return "", fmt.Errorf("error reading conflict file: %w", err)
+	}
+	originalContent, err := os.ReadFile(pair.OriginalPath)
+	if err != nil {
+		return "", fmt.Errorf("error reading original file: %w", err)
+	}
+	return classify(conflictContent, originalContent, level)
+}
+
+// classify compares the lines of a conflict copy and its original, in
+// order, at the given equivalence level. One side is a subset when the
+// other only adds lines to it.
+func classify(conflict, original []byte, level string) (string, error) {
+	same, err := equivalent(conflict, original, level)
+	if err != nil {
+		return "", err
+	}
+	if same {
+		return ClassIdentical, nil
+	}
+	if isBinary(conflict) || isBinary(original) {
+		return ClassDivergent, nil
+	}
+
+	rank, _ := equivalenceRank(level)
+	if rank > 0 {
+		// Whitespace is ignored line by line below, not stripped here.
+		conflict, original = normalize(conflict, min(rank, 3)), normalize(original, min(rank, 3))
+	}
+	stats := diff.ComputeStats(diff.Compute(
+		appendLines(conflict),
+		appendLines(original),
+		diff.Options{IgnoreAllSpace: rank >= 4},
+	))
+
+	switch {
+	case !stats.Changed():
+		return ClassIdentical, nil
+	case stats.Removed == 0:
+		return ClassConflictSubset, nil
+	case stats.Added == 0:
+		return ClassOriginalSubset, nil
+	default:
+		return ClassDivergent, nil
+	}
+}
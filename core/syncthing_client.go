@@ -0,0 +1,239 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+const DefaultSyncthingAddress = "127.0.0.1:8384"
+
+// SyncthingClient talks to the REST API of a running Syncthing.
+type SyncthingClient struct {
+	BaseURL string
+	APIKey  string
+	HTTP    *http.Client
+
+	folders []SyncthingFolder
+}
+
+// NewSyncthingClient accepts an address as Syncthing writes it in
+// config.xml, with or without a scheme.
+func NewSyncthingClient(address, apiKey string, tls bool) (*SyncthingClient, error) {
+	if address == "" {
+		address = DefaultSyncthingAddress
+	}
+	if !strings.Contains(address, "://") {
+		scheme := "http"
+		if tls {
+			scheme = "https"
+		}
+		address = scheme + "://" + address
+	}
+
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Syncthing address %q: %w", address, err)
+	}
+	// The GUI often listens on every interface; talk to it locally.
+	if host, port, err := net.SplitHostPort(u.Host); err == nil &&
+		(host == "0.0.0.0" || host == "::" || host == "") {
+		u.Host = net.JoinHostPort("127.0.0.1", port)
+	}
+
+	return &SyncthingClient{
+		BaseURL: strings.TrimSuffix(u.String(), "/"),
+		APIKey:  apiKey,
+		HTTP:    &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Folders lists the folders Syncthing shares, with their paths expanded.
+func (c *SyncthingClient) Folders() ([]SyncthingFolder, error) {
+	if c.folders != nil {
+		return c.folders, nil
+	}
+
+	var folders []SyncthingFolder
+	if err := c.do(http.MethodGet, "/rest/config/folders", nil, &folders); err != nil {
+		return nil, err
+	}
+	for i, folder := range folders {
+		expanded, err := homedir.Expand(folder.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand path %s: %w", folder.Path, err)
+		}
+		folders[i].Path = filepath.Clean(expanded)
+	}
+
+	c.folders = folders
+	return folders, nil
+}
+
+// Scan asks Syncthing to rescan the given subdirectories of a folder, or
+// all of it when there are none.
+func (c *SyncthingClient) Scan(folderID string, subs []string) error {
+	query := url.Values{"folder": {folderID}}
+	for _, sub := range subs {
+		query.Add("sub", sub)
+	}
+	return c.do(http.MethodPost, "/rest/db/scan", query, nil)
+}
+
+// Rescan scans the directories holding paths in whichever folders contain
+// them. Paths outside every folder are left alone.
+func (c *SyncthingClient) Rescan(paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	folders, err := c.Folders()
+	if err != nil {
+		return err
+	}
+
+	subs := map[string]map[string]bool{}
+	for _, path := range paths {
+		folder, rel, ok := folderFor(folders, path)
+		if !ok {
+			continue
+		}
+		if subs[folder.ID] == nil {
+			subs[folder.ID] = map[string]bool{}
+		}
+		dir := filepath.ToSlash(filepath.Dir(rel))
+		if dir == "." {
+			dir = ""
+		}
+		subs[folder.ID][dir] = true
+	}
+
+	ids := make([]string, 0, len(subs))
+	for id := range subs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		var list []string
+		for sub := range subs[id] {
+			if sub == "" {
+				// The folder root itself changed; scan everything.
+				list = nil
+				break
+			}
+			list = append(list, sub)
+		}
+		sort.Strings(list)
+		if err := c.Scan(id, list); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// folderFor finds the innermost folder containing path.
+func folderFor(
+	folders []SyncthingFolder,
+	path string,
+) (SyncthingFolder, string, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return SyncthingFolder{}, "", false
+	}
+
+	var best SyncthingFolder
+	found := false
+	for _, folder := range folders {
+		if isWithin(folder.Path, abs) && len(folder.Path) > len(best.Path) {
+			best, found = folder, true
+		}
+	}
+	if !found {
+		return SyncthingFolder{}, "", false
+	}
+	rel, err := filepath.Rel(best.Path, abs)
+	if err != nil {
+		return SyncthingFolder{}, "", false
+	}
+	return best, rel, true
+}
+
+func (c *SyncthingClient) do(
+	method, path string,
+	query url.Values,
+	result interface{},
+) error {
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-API-Key", c.APIKey)
+
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("syncthing %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf(
+			"syncthing %s %s: %s: %s",
+			method,
+			path,
+			resp.Status,
+			strings.TrimSpace(string(body)),
+		)
+	}
+
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("syncthing %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// rescannerOrNil keeps a nil client from becoming a non-nil interface.
+func rescannerOrNil(client *SyncthingClient) Rescanner {
+	if client == nil {
+		return nil
+	}
+	return client
+}
+
+// rescan hands the paths ops changed to rescanner. Failing to trigger a
+// scan is not fatal; Syncthing will notice the changes on its own later.
+func rescan(logger logr.Logger, rescanner Rescanner, ops *FileOps) {
+	if rescanner == nil || ops == nil {
+		return
+	}
+	changed := ops.takeChanged()
+	if len(changed) == 0 {
+		return
+	}
+	if err := rescanner.Rescan(changed); err != nil {
+		logger.Error(err, "Failed to ask Syncthing to rescan")
+		return
+	}
+	logger.V(1).Info("Asked Syncthing to rescan", "paths", len(changed))
+}
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// SyncthingConfig is the part of Syncthing's config.xml lessmay reads.
+type SyncthingConfig struct {
+	Devices []SyncthingDevice `xml:"device"`
+	Folders []SyncthingFolder `xml:"folder"`
+	GUI     SyncthingGUI      `xml:"gui"`
+}
+
+type SyncthingDevice struct {
+	ID   string `xml:"id,attr"`
+	Name string `xml:"name,attr"`
+}
+
+// SyncthingFolder is shaped like the folders in both config.xml and the
+// REST API.
+type SyncthingFolder struct {
+	ID    string `xml:"id,attr" json:"id"`
+	Label string `xml:"label,attr" json:"label"`
+	Path  string `xml:"path,attr" json:"path"`
+}
+
+type SyncthingGUI struct {
+	TLS     bool   `xml:"tls,attr"`
+	Address string `xml:"address"`
+	APIKey  string `xml:"apikey"`
+}
+
+func ReadSyncthingConfig(path string) (*SyncthingConfig, error) {
+	expanded, err := homedir.Expand(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand path %s: %w", path, err)
+	}
+	data, err := os.ReadFile(expanded)
+	if err != nil {
+		return nil, err
+	}
+
+	var config SyncthingConfig
+	if err := xml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	return &config, nil
+}
+
+// FindSyncthingConfig returns the first config.xml found where Syncthing
+// keeps it on this platform, or "" if there is none.
+func FindSyncthingConfig() string {
+	for _, path := range syncthingConfigPaths() {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+func syncthingConfigPaths() []string {
+	home, err := homedir.Dir()
+	if err != nil {
+		return nil
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{
+			filepath.Join(home, "Library", "Application Support", "Syncthing", "config.xml"),
+		}
+	case "windows":
+		localAppData := os.Getenv("LOCALAPPDATA")
+		if localAppData == "" {
+			localAppData = filepath.Join(home, "AppData", "Local")
+		}
+		return []string{filepath.Join(localAppData, "Syncthing", "config.xml")}
+	}
+
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if stateHome == "" {
+		stateHome = filepath.Join(home, ".local", "state")
+	}
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(home, ".config")
+	}
+	// Syncthing 1.27 moved its config from XDG_CONFIG_HOME to
+	// XDG_STATE_HOME.
+	return []string{
+		filepath.Join(stateHome, "syncthing", "config.xml"),
+		filepath.Join(configHome, "syncthing", "config.xml"),
+	}
+}
+
+// loadSyncthingConfig reads path, or the default location when path is
+// empty. Only an explicitly given config has to exist.
+func loadSyncthingConfig(path string) (*SyncthingConfig, error) {
+	explicit := path != ""
+	if !explicit {
+		path = FindSyncthingConfig()
+		if path == "" {
+			return nil, nil
+		}
+	}
+
+	config, err := ReadSyncthingConfig(path)
+	if errors.Is(err, fs.ErrNotExist) && !explicit {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Syncthing config: %w", err)
+	}
+	return config, nil
+}
@@ -0,0 +1,148 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gkwa/lessmay/internal/diff"
+)
+
+const (
+	stversionsDir        = ".stversions"
+	stversionsTimeLayout = "20060102-150405"
+)
+
+type MergeResult struct {
+	Clean    bool
+	Content  []byte
+	Strategy string
+	Reason   string
+}
+
+type ThreeWayMerger struct{}
+
+func (m *ThreeWayMerger) Merge(pair ConflictPair) (MergeResult, error) {
+	result := MergeResult{Strategy: "three-way"}
+
+	basePath, err := findMergeBase(pair)
+	if err != nil {
+		return result, err
+	}
+	if basePath == "" {
+		result.Reason = "no older version found in " + stversionsDir
+		return result, nil
+	}
+
+	var contents [3][]byte
+	for i, path := range []string{basePath, pair.OriginalPath, pair.ConflictPath} {
+		contents[i], err = os.ReadFile(path)
+		if err != nil {
+			return result, fmt.Errorf("error reading %s: %w", path, err)
+		}
+		if isBinary(contents[i]) {
+			result.Reason = "binary files cannot be merged"
+			return result, nil
+		}
+	}
+
+	merged, overlaps := diff.Merge3(
+		diff.SplitLines(string(contents[0])),
+		diff.SplitLines(string(contents[1])),
+		diff.SplitLines(string(contents[2])),
+	)
+	if len(overlaps) > 0 {
+		ranges := make([]string, len(overlaps))
+		for i, o := range overlaps {
+			ranges[i] = fmt.Sprintf("%d-%d", o.BaseStart+1, o.BaseEnd)
+		}
+		result.Reason = fmt.Sprintf(
+			"both sides changed lines %s of %s",
+			strings.Join(ranges, ", "),
+			basePath,
+		)
+		return result, nil
+	}
+
+	content := []byte(strings.Join(merged, ""))
+	if isJSONDocument(pair.OriginalPath) && json.Valid(contents[1]) && !json.Valid(content) {
+		result.Reason = "merging the lines does not give valid JSON"
+		return result, nil
+	}
+
+	result.Clean = true
+	result.Content = content
+	return result, nil
+}
+
+// findMergeBase returns the newest copy of the original in the Syncthing
+// folder's .stversions that predates both sides of the conflict, or "" if
+// there is none.
+func findMergeBase(pair ConflictPair) (string, error) {
+	root := findStversionsRoot(filepath.Dir(pair.OriginalPath))
+	if root == "" {
+		return "", nil
+	}
+
+	rel, err := filepath.Rel(root, pair.OriginalPath)
+	if err != nil {
+		return "", fmt.Errorf("error locating %s: %w", pair.OriginalPath, err)
+	}
+
+	dir := filepath.Join(root, stversionsDir, filepath.Dir(rel))
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error reading %s: %w", dir, err)
+	}
+
+	cutoff := pair.ConflictModTime
+	if pair.OriginalModTime.Before(cutoff) {
+		cutoff = pair.OriginalModTime
+	}
+
+	name := filepath.Base(rel)
+	ext := filepath.Ext(name)
+	prefix := strings.TrimSuffix(name, ext) + "~"
+
+	var best string
+	var bestTime time.Time
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		tag, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok {
+			continue
+		}
+		tag, ok = strings.CutSuffix(tag, ext)
+		if !ok {
+			continue
+		}
+		versionTime, err := time.ParseInLocation(
+			stversionsTimeLayout,
+			tag,
+			time.Local,
+		)
+		if err != nil || !versionTime.Before(cutoff) {
+			continue
+		}
+		if best == "" || versionTime.After(bestTime) {
+			best = filepath.Join(dir, entry.Name())
+			bestTime = versionTime
+		}
+	}
+
+	return best, nil
+}
+
+func findStversionsRoot(dir string) string {
+	return findAncestorWith(dir, stversionsDir)
+}
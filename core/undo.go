@@ -0,0 +1,248 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+type JournalRun struct {
+	ID      string
+	Started time.Time
+	Changes int
+	Undone  bool
+}
+
+func JournalRuns(dir string) ([]JournalRun, error) {
+	entries, err := ReadJournal(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []JournalRun
+	index := map[string]int{}
+	for _, entry := range entries {
+		i, ok := index[entry.Run]
+		if !ok {
+			i = len(runs)
+			index[entry.Run] = i
+			runs = append(runs, JournalRun{ID: entry.Run, Started: entry.Time})
+		}
+		if entry.Op == JournalUndo {
+			runs[i].Undone = true
+		} else {
+			runs[i].Changes++
+		}
+	}
+
+	return runs, nil
+}
+
+// Undo reverts every change recorded for runID, newest first. An empty
+// runID selects the most recent run that has not been undone yet.
+func Undo(
+	logger logr.Logger,
+	dir, runID string,
+	dryRun bool,
+	out io.Writer,
+) error {
+	dir, err := journalDir(dir)
+	if err != nil {
+		return err
+	}
+
+	entries, err := ReadJournal(dir)
+	if err != nil {
+		return err
+	}
+
+	runs, err := JournalRuns(dir)
+	if err != nil {
+		return err
+	}
+
+	if runID == "" {
+		for i := len(runs) - 1; i >= 0; i-- {
+			if !runs[i].Undone && runs[i].Changes > 0 {
+				runID = runs[i].ID
+				break
+			}
+		}
+		if runID == "" {
+			return errors.New("nothing to undo")
+		}
+	}
+
+	var changes []JournalEntry
+	for _, entry := range entries {
+		if entry.Run != runID {
+			continue
+		}
+		if entry.Op == JournalUndo {
+			return fmt.Errorf("run %s has already been undone", runID)
+		}
+		changes = append(changes, entry)
+	}
+	if len(changes) == 0 {
+		return fmt.Errorf("no changes recorded for run %s", runID)
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Seq > changes[j].Seq
+	})
+
+	logger.Info("Undoing run", "run", runID, "changes", len(changes))
+
+	var errs []error
+	for _, entry := range changes {
+		if err := undoEntry(entry, dryRun, out); err != nil {
+			logger.Error(
+				err,
+				"Failed to undo change",
+				"op",
+				entry.Op,
+				"path",
+				entry.Path,
+			)
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	if dryRun {
+		return nil
+	}
+	return appendJournal(dir, JournalEntry{
+		Run:  runID,
+		Time: time.Now(),
+		Op:   JournalUndo,
+	})
+}
+
+func undoEntry(entry JournalEntry, dryRun bool, out io.Writer) error {
+	switch entry.Op {
+	case JournalRename:
+		if dryRun {
+			fmt.Fprintf(
+				out,
+				"[dry-run] would rename %s back to %s\n",
+				entry.Target,
+				entry.Path,
+			)
+			return nil
+		}
+		if err := ensureAbsent(entry.Path); err != nil {
+			return err
+		}
+		if err := os.Rename(entry.Target, entry.Path); err != nil {
+			return fmt.Errorf("error renaming %s back: %w", entry.Target, err)
+		}
+		fmt.Fprintf(out, "Renamed %s back to %s\n", entry.Target, entry.Path)
+
+	case JournalWrite:
+		if dryRun {
+			fmt.Fprintf(
+				out,
+				"[dry-run] would restore previous content of %s\n",
+				entry.Path,
+			)
+			return nil
+		}
+		if err := restoreBackup(entry.Backup, entry.Path); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Restored previous content of %s\n", entry.Path)
+
+	case JournalDispose:
+		if dryRun {
+			fmt.Fprintf(out, "[dry-run] would restore %s\n", entry.Path)
+			return nil
+		}
+		if err := ensureAbsent(entry.Path); err != nil {
+			return err
+		}
+		if err := restoreDisposed(entry); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Restored %s\n", entry.Path)
+
+	default:
+		return fmt.Errorf("unknown journal operation %q", entry.Op)
+	}
+
+	return nil
+}
+
+// restoreDisposed moves a trashed or quarantined file back where it came
+// from and falls back to the journal's backup copy otherwise.
+func restoreDisposed(entry JournalEntry) error {
+	if err := os.MkdirAll(filepath.Dir(entry.Path), 0o755); err != nil {
+		return err
+	}
+
+	if entry.Target != "" {
+		if _, err := os.Stat(entry.Target); err == nil {
+			if err := moveFile(entry.Target, entry.Path); err != nil {
+				return fmt.Errorf("error restoring %s: %w", entry.Target, err)
+			}
+			removeTrashInfo(entry.Target)
+			return nil
+		}
+	}
+
+	return restoreBackup(entry.Backup, entry.Path)
+}
+
+func restoreBackup(backup, path string) error {
+	if backup == "" {
+		return fmt.Errorf("no backup recorded for %s", path)
+	}
+	content, err := os.ReadFile(backup)
+	if err != nil {
+		return fmt.Errorf("error reading backup of %s: %w", path, err)
+	}
+
+	perm := fs.FileMode(0o644)
+	if info, err := os.Stat(backup); err == nil {
+		perm = info.Mode().Perm()
+	}
+	if err := os.WriteFile(path, content, perm); err != nil {
+		return fmt.Errorf("error restoring %s: %w", path, err)
+	}
+	return nil
+}
+
+func removeTrashInfo(trashed string) {
+	filesDir := filepath.Dir(trashed)
+	if filepath.Base(filesDir) != "files" {
+		return
+	}
+	info := filepath.Join(
+		filepath.Dir(filesDir),
+		"info",
+		filepath.Base(trashed)+".trashinfo",
+	)
+	if _, err := os.Stat(info); err == nil {
+		os.Remove(info)
+	}
+}
+
+func ensureAbsent(path string) error {
+	_, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%s already exists, not overwriting it", path)
+}
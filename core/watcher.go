@@ -0,0 +1,248 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-logr/logr"
+)
+
+const DefaultDebounce = 2 * time.Second
+
+type Watcher struct {
+	resolver  *SyncConflictResolver
+	finder    *DefaultFileFinder
+	skipPaths []string
+	filters   []*pathFilter
+	debounce  time.Duration
+	logger    logr.Logger
+}
+
+func NewWatcher(
+	logger logr.Logger,
+	opts Options,
+	skipPaths []string,
+	debounce time.Duration,
+) (*Watcher, error) {
+	// A JSON array is only complete once the last conflict is in, which
+	// never happens while watching.
+	if opts.Output == OutputJSON {
+		return nil, fmt.Errorf(
+			"watch cannot write a single JSON document, use --output %s instead",
+			OutputNDJSON,
+		)
+	}
+	resolver, err := NewSyncConflictResolver(logger, opts)
+	if err != nil {
+		return nil, err
+	}
+	finder, err := opts.fileFinder(logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if debounce <= 0 {
+		debounce = DefaultDebounce
+	}
+
+	return &Watcher{
+		resolver:  resolver,
+		finder:    finder,
+		skipPaths: skipPaths,
+		debounce:  debounce,
+		logger:    logger,
+	}, nil
+}
+
+// Run resolves the conflicts that already exist under paths and then every
+// new one as it appears, until ctx is cancelled. A conflict file is only
+// picked up once it has not changed for the debounce interval so that
+// Syncthing can finish writing it.
+func (w *Watcher) Run(ctx context.Context, paths []string) error {
+	// Debounce timers that already fired wait on ctx to hand over their
+	// path, so cancel it whichever way Run returns.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %w", err)
+	}
+	defer fsw.Close()
+
+	for _, path := range paths {
+		filter, err := w.finder.filter(path, w.skipPaths)
+		if err != nil {
+			return err
+		}
+		w.filters = append(w.filters, filter)
+		if err := w.addRecursive(fsw, path); err != nil {
+			return err
+		}
+	}
+
+	if err := w.resolver.ResolveSyncConflicts(paths, w.skipPaths); err != nil {
+		return err
+	}
+
+	ready := make(chan string)
+	var mu sync.Mutex
+	timers := map[string]*time.Timer{}
+	defer func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, timer := range timers {
+			timer.Stop()
+		}
+	}()
+
+	schedule := func(path string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if timer, ok := timers[path]; ok {
+			timer.Reset(w.debounce)
+			return
+		}
+		timers[path] = time.AfterFunc(w.debounce, func() {
+			mu.Lock()
+			delete(timers, path)
+			mu.Unlock()
+			select {
+			case ready <- path:
+			case <-ctx.Done():
+			}
+		})
+	}
+
+	w.logger.Info("Watching for sync conflicts", "paths", paths)
+
+	count := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case err, ok := <-fsw.Errors:
+			if !ok {
+				return nil
+			}
+			w.logger.Error(err, "Watcher error")
+
+		case event, ok := <-fsw.Events:
+			if !ok {
+				return nil
+			}
+			w.handleEvent(fsw, event, schedule)
+
+		case path := <-ready:
+			pair, ok, err := w.finder.conflictPair(path)
+			if errors.Is(err, fs.ErrNotExist) || !ok {
+				continue
+			}
+			if err != nil {
+				w.logger.Error(
+					err,
+					"Failed to read sync conflict",
+					"conflictFile",
+					path,
+				)
+				continue
+			}
+			count++
+			if err := w.resolver.resolveAndReport(pair, count, nil); err != nil {
+				return err
+			}
+			if err := w.resolver.flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (w *Watcher) handleEvent(
+	fsw *fsnotify.Watcher,
+	event fsnotify.Event,
+	schedule func(string),
+) {
+	if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) {
+		return
+	}
+	info, err := os.Lstat(event.Name)
+	if err != nil {
+		return
+	}
+	if w.skipped(event.Name, info.IsDir()) {
+		return
+	}
+
+	if info.IsDir() {
+		if event.Has(fsnotify.Create) {
+			if err := w.addRecursive(fsw, event.Name); err != nil {
+				w.logger.Error(
+					err,
+					"Failed to watch directory",
+					"path",
+					event.Name,
+				)
+			}
+			w.scheduleExisting(event.Name, schedule)
+		}
+		return
+	}
+
+	if _, _, ok := detect(event.Name, w.finder.detectorsFor(event.Name)); ok {
+		w.logger.V(1).Info(
+			"Sync conflict changed",
+			"conflictFile",
+			event.Name,
+		)
+		schedule(event.Name)
+	}
+}
+
+// scheduleExisting picks up conflict files that landed in a new directory
+// before it was being watched.
+func (w *Watcher) scheduleExisting(dir string, schedule func(string)) {
+	pairs, err := w.finder.FindSyncConflictFiles(
+		[]string{dir},
+		w.skipPaths,
+	)
+	if err != nil {
+		w.logger.Error(err, "Failed to scan directory", "path", dir)
+		return
+	}
+	for _, pair := range pairs {
+		schedule(pair.ConflictPath)
+	}
+}
+
+func (w *Watcher) addRecursive(fsw *fsnotify.Watcher, root string) error {
+	return w.finder.walk(
+		root,
+		w.skipPaths,
+		func(path string, d fs.DirEntry) error {
+			if !d.IsDir() {
+				return nil
+			}
+			if err := fsw.Add(path); err != nil {
+				return fmt.Errorf("error watching %s: %w", path, err)
+			}
+			return nil
+		},
+	)
+}
+
+func (w *Watcher) skipped(path string, isDir bool) bool {
+	for _, filter := range w.filters {
+		if skip, skipDir := filter.check(path, isDir); skip && (!isDir || skipDir) {
+			return true
+		}
+	}
+	return false
+}
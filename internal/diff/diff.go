@@ -0,0 +1,177 @@
+package diff
+
+import (
+	"strings"
+	"unicode"
+)
+
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+// Edit refers to lines by index. A is valid for Equal and Delete, B for
+// Equal and Insert.
+type Edit struct {
+	Op Op
+	A  int
+	B  int
+}
+
+type Options struct {
+	IgnoreAllSpace bool
+}
+
+// SplitLines keeps the trailing newline on every line so that a missing
+// newline at the end of a file shows up as a difference.
+func SplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func Compute(a, b []string, opts Options) []Edit {
+	ka, kb := keys(a, opts), keys(b, opts)
+
+	prefix := 0
+	for prefix < len(ka) && prefix < len(kb) && ka[prefix] == kb[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(ka)-prefix && suffix < len(kb)-prefix &&
+		ka[len(ka)-1-suffix] == kb[len(kb)-1-suffix] {
+		suffix++
+	}
+
+	edits := make([]Edit, 0, len(a)+len(b))
+	for i := 0; i < prefix; i++ {
+		edits = append(edits, Edit{Op: Equal, A: i, B: i})
+	}
+	for _, e := range myers(
+		ka[prefix:len(ka)-suffix],
+		kb[prefix:len(kb)-suffix],
+	) {
+		e.A += prefix
+		e.B += prefix
+		edits = append(edits, e)
+	}
+	for i := suffix; i > 0; i-- {
+		edits = append(edits, Edit{
+			Op: Equal,
+			A:  len(a) - i,
+			B:  len(b) - i,
+		})
+	}
+	return edits
+}
+
+func keys(lines []string, opts Options) []string {
+	if !opts.IgnoreAllSpace {
+		return lines
+	}
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		out[i] = strings.Map(func(r rune) rune {
+			if unicode.IsSpace(r) {
+				return -1
+			}
+			return r
+		}, line)
+	}
+	return out
+}
+
+// myers implements the greedy O((N+M)D) algorithm from "An O(ND) Difference
+// Algorithm and Its Variations". Only the active diagonals are kept for each
+// step so memory stays at O(D^2).
+func myers(a, b []string) []Edit {
+	n, m := len(a), len(b)
+	if n == 0 && m == 0 {
+		return nil
+	}
+
+	// trace[d][k+d] holds the furthest x reached on diagonal k after d edits.
+	var trace [][]int
+	for d := 0; ; d++ {
+		v := make([]int, 2*d+1)
+		for k := -d; k <= d; k += 2 {
+			var x int
+			switch {
+			case d == 0:
+				x = 0
+			case k == -d:
+				x = trace[d-1][k+1+d-1]
+			case k == d:
+				x = trace[d-1][k-1+d-1] + 1
+			case trace[d-1][k-1+d-1] < trace[d-1][k+1+d-1]:
+				x = trace[d-1][k+1+d-1]
+			default:
+				x = trace[d-1][k-1+d-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[k+d] = x
+			if x >= n && y >= m {
+				trace = append(trace, v)
+				return backtrack(trace, d, n, m)
+			}
+		}
+		trace = append(trace, v)
+	}
+}
+
+func backtrack(trace [][]int, d, n, m int) []Edit {
+	var reversed []Edit
+	x, y := n, m
+	for ; d >= 0; d-- {
+		k := x - y
+		if d == 0 {
+			for x > 0 && y > 0 {
+				x--
+				y--
+				reversed = append(reversed, Edit{Op: Equal, A: x, B: y})
+			}
+			break
+		}
+
+		prev := trace[d-1]
+		var prevK int
+		if k == -d || (k != d && prev[k-1+d-1] < prev[k+1+d-1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := prev[prevK+d-1]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			reversed = append(reversed, Edit{Op: Equal, A: x, B: y})
+		}
+		if x == prevX {
+			y--
+			reversed = append(reversed, Edit{Op: Insert, A: x, B: y})
+		} else {
+			x--
+			reversed = append(reversed, Edit{Op: Delete, A: x, B: y})
+		}
+	}
+
+	edits := make([]Edit, len(reversed))
+	for i, e := range reversed {
+		edits[len(reversed)-1-i] = e
+	}
+	return edits
+}
@@ -0,0 +1,255 @@
+package diff
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func applyEdits(a, b []string, edits []Edit) []string {
+	var out []string
+	for _, e := range edits {
+		switch e.Op {
+		case Equal:
+			out = append(out, a[e.A])
+		case Insert:
+			out = append(out, b[e.B])
+		}
+	}
+	return out
+}
+
+func TestCompute(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        string
+		b        string
+		expected Stats
+	}{
+		{name: "identical", a: "a\nb\nc\n", b: "a\nb\nc\n"},
+		{name: "both empty"},
+		{name: "insert into empty", a: "", b: "a\nb\n", expected: Stats{Added: 2}},
+		{name: "delete all", a: "a\nb\n", b: "", expected: Stats{Removed: 2}},
+		{
+			name:     "change in middle",
+			a:        "a\nb\nc\nd\n",
+			b:        "a\nx\nc\nd\n",
+			expected: Stats{Added: 1, Removed: 1},
+		},
+		{
+			name:     "interleaved",
+			a:        "a\nb\nc\na\nb\nb\na\n",
+			b:        "c\nb\na\nb\na\nc\n",
+			expected: Stats{Added: 2, Removed: 3},
+		},
+		{
+			name:     "missing trailing newline",
+			a:        "a\nb\n",
+			b:        "a\nb",
+			expected: Stats{Added: 1, Removed: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := SplitLines(tt.a), SplitLines(tt.b)
+			edits := Compute(a, b, Options{})
+
+			if got := ComputeStats(edits); got != tt.expected {
+				t.Errorf("Expected stats %+v, got %+v", tt.expected, got)
+			}
+
+			got := strings.Join(applyEdits(a, b, edits), "")
+			if got != tt.b {
+				t.Errorf("Applying edits produced %q, expected %q", got, tt.b)
+			}
+		})
+	}
+}
+
+func TestComputeIgnoreAllSpace(t *testing.T) {
+	a := SplitLines("a b\n  c\n")
+	b := SplitLines("ab\nc  \n")
+
+	stats := ComputeStats(Compute(a, b, Options{IgnoreAllSpace: true}))
+	if stats.Changed() {
+		t.Errorf("Expected no changes when ignoring whitespace, got %+v", stats)
+	}
+
+	stats = ComputeStats(Compute(a, b, Options{}))
+	if !stats.Changed() {
+		t.Error("Expected changes when whitespace is significant")
+	}
+}
+
+func TestWriteUnified(t *testing.T) {
+	a := SplitLines("1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n")
+	b := SplitLines("1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\nsixteen")
+
+	var buf bytes.Buffer
+	stats, err := WriteUnified(&buf, "a.md", "b.md", a, b, Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := `--- a.md
++++ b.md
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -13,3 +13,4 @@
+ 13
+ 14
+ 15
++sixteen
+\ No newline at end of file
+`
+	if buf.String() != expected {
+		t.Errorf("Unexpected unified diff:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+	if stats != (Stats{Added: 2, Removed: 1}) {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestBlocks(t *testing.T) {
+	a := SplitLines("a\nb\nc\nd\ne\n")
+	b := SplitLines("a\nB\nc\ne\nf\n")
+
+	blocks := Blocks(Compute(a, b, Options{}))
+	expected := []Block{
+		{A0: 1, A1: 2, B0: 1, B1: 2},
+		{A0: 3, A1: 4, B0: 3, B1: 3},
+		{A0: 5, A1: 5, B0: 4, B1: 5},
+	}
+
+	if len(blocks) != len(expected) {
+		t.Fatalf("Expected %d blocks, got %d: %+v", len(expected), len(blocks), blocks)
+	}
+	for i := range expected {
+		if blocks[i] != expected[i] {
+			t.Errorf("Block %d: expected %+v, got %+v", i, expected[i], blocks[i])
+		}
+	}
+}
+
+func TestMerge3(t *testing.T) {
+	tests := []struct {
+		name           string
+		base           string
+		a              string
+		b              string
+		expected       string
+		expectOverlaps int
+	}{
+		{
+			name:     "changes in different regions",
+			base:     "1\n2\n3\n4\n5\n6\n",
+			a:        "one\n2\n3\n4\n5\n6\n",
+			b:        "1\n2\n3\n4\n5\nsix\n",
+			expected: "one\n2\n3\n4\n5\nsix\n",
+		},
+		{
+			name:     "same change on both sides",
+			base:     "1\n2\n3\n",
+			a:        "1\ntwo\n3\n",
+			b:        "1\ntwo\n3\n",
+			expected: "1\ntwo\n3\n",
+		},
+		{
+			name:     "insert and delete apart",
+			base:     "1\n2\n3\n4\n5\n",
+			a:        "0\n1\n2\n3\n4\n5\n",
+			b:        "1\n2\n3\n5\n",
+			expected: "0\n1\n2\n3\n5\n",
+		},
+		{
+			name:           "overlapping changes",
+			base:           "1\n2\n3\n",
+			a:              "1\nA\n3\n",
+			b:              "1\nB\n3\n",
+			expectOverlaps: 1,
+		},
+		{
+			name:           "insertions at the same place",
+			base:           "1\n2\n",
+			a:              "1\nA\n2\n",
+			b:              "1\nB\n2\n",
+			expectOverlaps: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, overlaps := Merge3(
+				SplitLines(tt.base),
+				SplitLines(tt.a),
+				SplitLines(tt.b),
+			)
+			if len(overlaps) != tt.expectOverlaps {
+				t.Fatalf(
+					"Expected %d overlaps, got %d",
+					tt.expectOverlaps,
+					len(overlaps),
+				)
+			}
+			if tt.expectOverlaps > 0 {
+				return
+			}
+			if got := strings.Join(merged, ""); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestNWay(t *testing.T) {
+	base := SplitLines("a\nb\nc\n")
+	others := [][]string{
+		SplitLines("a\nb\nc\nd\n"),
+		SplitLines("a\nB\nc\nd\n"),
+	}
+
+	rows := NWay(base, others, Options{})
+	expected := []struct {
+		text     string
+		baseLine int
+		present  string
+	}{
+		{"a\n", 0, "xxx"},
+		{"b\n", 1, "xx."},
+		{"B\n", -1, "..x"},
+		{"c\n", 2, "xxx"},
+		{"d\n", -1, ".xx"},
+	}
+
+	if len(rows) != len(expected) {
+		t.Fatalf("Expected %d rows, got %d: %+v", len(expected), len(rows), rows)
+	}
+	for i, want := range expected {
+		got := rows[i]
+		present := ""
+		for _, p := range got.Present {
+			if p {
+				present += "x"
+			} else {
+				present += "."
+			}
+		}
+		if got.Text != want.text || got.BaseLine != want.baseLine || present != want.present {
+			t.Errorf(
+				"Row %d: expected %q %d %s, got %q %d %s",
+				i, want.text, want.baseLine, want.present,
+				got.Text, got.BaseLine, present,
+			)
+		}
+	}
+	if !rows[0].Common() || rows[1].Common() {
+		t.Error("Expected only lines every version has to be common")
+	}
+}
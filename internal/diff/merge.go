@@ -0,0 +1,90 @@
+package diff
+
+import "sort"
+
+// Overlap is a region of base that both sides changed in different ways.
+type Overlap struct {
+	BaseStart int
+	BaseEnd   int
+}
+
+type sideBlock struct {
+	Block
+	side int
+}
+
+// Merge3 applies the changes base->a and base->b to base. Changes that touch
+// the same region of base are only accepted when both sides made the same
+// edit; otherwise the region is reported in the returned overlaps and
+// merged is nil.
+func Merge3(base, a, b []string) (merged []string, overlaps []Overlap) {
+	sides := [2][]string{a, b}
+
+	var all []sideBlock
+	for side, lines := range sides {
+		for _, blk := range Blocks(Compute(base, lines, Options{})) {
+			all = append(all, sideBlock{Block: blk, side: side})
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].A0 < all[j].A0
+	})
+
+	prev := 0
+	for i := 0; i < len(all); {
+		lo, hi := all[i].A0, all[i].A1
+		var group [2][]Block
+		for ; i < len(all) && all[i].A0 <= hi; i++ {
+			group[all[i].side] = append(group[all[i].side], all[i].Block)
+			hi = max(hi, all[i].A1)
+		}
+
+		merged = append(merged, base[prev:lo]...)
+		prev = hi
+
+		switch {
+		case len(group[1]) == 0:
+			merged = append(merged, replace(base, a, group[0], lo, hi)...)
+		case len(group[0]) == 0:
+			merged = append(merged, replace(base, b, group[1], lo, hi)...)
+		default:
+			ra := replace(base, a, group[0], lo, hi)
+			rb := replace(base, b, group[1], lo, hi)
+			if !equalLines(ra, rb) {
+				overlaps = append(overlaps, Overlap{BaseStart: lo, BaseEnd: hi})
+			}
+			merged = append(merged, ra...)
+		}
+	}
+	merged = append(merged, base[prev:]...)
+
+	if len(overlaps) > 0 {
+		return nil, overlaps
+	}
+	return merged, nil
+}
+
+// replace returns what side made of base[lo:hi], given the blocks it
+// changed inside that range.
+func replace(base, side []string, blocks []Block, lo, hi int) []string {
+	var out []string
+	pos := lo
+	for _, blk := range blocks {
+		out = append(out, base[pos:blk.A0]...)
+		out = append(out, side[blk.B0:blk.B1]...)
+		pos = blk.A1
+	}
+	return append(out, base[pos:hi]...)
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
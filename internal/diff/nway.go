@@ -0,0 +1,77 @@
+package diff
+
+// Row is one line of an N-way comparison. Present has one entry per
+// version, base first, telling which versions contain the line.
+type Row struct {
+	Text string
+	// BaseLine is the line's index in base, or -1 if base lacks it.
+	BaseLine int
+	Present  []bool
+}
+
+// Common reports whether every version has the line.
+func (r Row) Common() bool {
+	for _, p := range r.Present {
+		if !p {
+			return false
+		}
+	}
+	return true
+}
+
+// NWay lines up every version against base. Lines a version deleted are
+// marked absent for it; lines versions added at the same place are shared
+// when their text matches, so identical edits from two devices show as one
+// row.
+func NWay(base []string, others [][]string, opts Options) []Row {
+	n := len(others) + 1
+
+	baseRows := make([]Row, len(base))
+	for i, line := range base {
+		present := make([]bool, n)
+		for v := range present {
+			present[v] = true
+		}
+		baseRows[i] = Row{Text: line, BaseLine: i, Present: present}
+	}
+
+	// inserted[i] holds the rows added before base line i.
+	inserted := make([][]Row, len(base)+1)
+
+	for v, lines := range others {
+		col := v + 1
+		anchor, next := 0, 0
+		for _, e := range Compute(base, lines, opts) {
+			switch e.Op {
+			case Equal:
+				anchor, next = e.A+1, 0
+			case Delete:
+				baseRows[e.A].Present[col] = false
+				anchor, next = e.A+1, 0
+			case Insert:
+				rows := inserted[anchor]
+				j := next
+				for j < len(rows) && rows[j].Text != lines[e.B] {
+					j++
+				}
+				if j == len(rows) {
+					present := make([]bool, n)
+					j = next
+					rows = append(rows, Row{})
+					copy(rows[j+1:], rows[j:])
+					rows[j] = Row{Text: lines[e.B], BaseLine: -1, Present: present}
+				}
+				rows[j].Present[col] = true
+				inserted[anchor] = rows
+				next = j + 1
+			}
+		}
+	}
+
+	out := make([]Row, 0, len(base))
+	for i := range base {
+		out = append(out, inserted[i]...)
+		out = append(out, baseRows[i])
+	}
+	return append(out, inserted[len(base)]...)
+}
@@ -0,0 +1,188 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+const DefaultContext = 3
+
+type Hunk struct {
+	AStart int
+	ALen   int
+	BStart int
+	BLen   int
+	Edits  []Edit
+}
+
+type Stats struct {
+	Added   int
+	Removed int
+}
+
+func (s Stats) Changed() bool {
+	return s.Added > 0 || s.Removed > 0
+}
+
+func ComputeStats(edits []Edit) Stats {
+	var s Stats
+	for _, e := range edits {
+		switch e.Op {
+		case Insert:
+			s.Added++
+		case Delete:
+			s.Removed++
+		}
+	}
+	return s
+}
+
+func Hunks(edits []Edit, context int) []Hunk {
+	var hunks []Hunk
+
+	i := 0
+	for i < len(edits) {
+		for i < len(edits) && edits[i].Op == Equal {
+			i++
+		}
+		if i == len(edits) {
+			break
+		}
+
+		start := max(i-context, 0)
+		end := i
+		for end < len(edits) {
+			for end < len(edits) && edits[end].Op != Equal {
+				end++
+			}
+			run := end
+			for run < len(edits) && edits[run].Op == Equal {
+				run++
+			}
+			if run == len(edits) || run-end > 2*context {
+				end = min(end+context, len(edits))
+				break
+			}
+			end = run
+		}
+
+		hunks = append(hunks, newHunk(edits[start:end]))
+		i = end
+	}
+
+	return hunks
+}
+
+func newHunk(edits []Edit) Hunk {
+	h := Hunk{
+		AStart: edits[0].A,
+		BStart: edits[0].B,
+		Edits:  edits,
+	}
+	for _, e := range edits {
+		switch e.Op {
+		case Equal:
+			h.ALen++
+			h.BLen++
+		case Delete:
+			h.ALen++
+		case Insert:
+			h.BLen++
+		}
+	}
+	return h
+}
+
+func (h Hunk) Header() string {
+	return fmt.Sprintf(
+		"@@ -%s +%s @@",
+		hunkRange(h.AStart, h.ALen),
+		hunkRange(h.BStart, h.BLen),
+	)
+}
+
+func hunkRange(start, length int) string {
+	switch length {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	default:
+		return fmt.Sprintf("%d,%d", start+1, length)
+	}
+}
+
+func WriteUnified(
+	w io.Writer,
+	aName, bName string,
+	a, b []string,
+	opts Options,
+) (Stats, error) {
+	edits := Compute(a, b, opts)
+	stats := ComputeStats(edits)
+	if !stats.Changed() {
+		return stats, nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", aName, bName)
+	for _, h := range Hunks(edits, DefaultContext) {
+		sb.WriteString(h.Header())
+		sb.WriteString("\n")
+		for _, e := range h.Edits {
+			switch e.Op {
+			case Equal:
+				writeLine(&sb, " ", a[e.A])
+			case Delete:
+				writeLine(&sb, "-", a[e.A])
+			case Insert:
+				writeLine(&sb, "+", b[e.B])
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return stats, err
+}
+
+func writeLine(sb *strings.Builder, prefix, line string) {
+	sb.WriteString(prefix)
+	sb.WriteString(line)
+	if !strings.HasSuffix(line, "\n") {
+		sb.WriteString("\n\\ No newline at end of file\n")
+	}
+}
+
+// Block is a run of changes with no context: a[A0:A1] was replaced by
+// b[B0:B1].
+type Block struct {
+	A0, A1 int
+	B0, B1 int
+}
+
+func Blocks(edits []Edit) []Block {
+	var blocks []Block
+	for i := 0; i < len(edits); {
+		if edits[i].Op == Equal {
+			i++
+			continue
+		}
+		blk := Block{
+			A0: edits[i].A,
+			A1: edits[i].A,
+			B0: edits[i].B,
+			B1: edits[i].B,
+		}
+		for ; i < len(edits) && edits[i].Op != Equal; i++ {
+			switch edits[i].Op {
+			case Delete:
+				blk.A1 = edits[i].A + 1
+			case Insert:
+				blk.B1 = edits[i].B + 1
+			}
+		}
+		blocks = append(blocks, blk)
+	}
+	return blocks
+}